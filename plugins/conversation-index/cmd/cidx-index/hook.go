@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/shared"
+)
+
+// hookLockWait is how long a hook waits for another indexer before leaving
+// its transcript to the next hook, so tool calls are never held up for long
+const hookLockWait = time.Second
+
+// hookInput is the part of a hook's JSON input that names the transcript
+type hookInput struct {
+	SessionID      string `json:"session_id"`
+	TranscriptPath string `json:"transcript_path"`
+	CWD            string `json:"cwd"`
+}
+
+// hookOutput is the JSON printed for Claude Code; indexing has nothing to
+// show in the transcript
+type hookOutput struct {
+	SuppressOutput bool `json:"suppressOutput"`
+}
+
+// runHook implements `cidx-index --hook`: it indexes the transcript named by
+// the hook input read from stdin, along with its subagents' transcripts,
+// without scanning other projects. Problems are reported on stderr, but it
+// always prints valid hook output and exits 0 so it never blocks Claude.
+// Nothing else may write to stdout while it runs.
+func runHook(stdin io.Reader, pruneOlderThan string, jobs int) {
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintf(os.Stderr, "cidx-index: %v\n", r)
+		}
+		json.NewEncoder(os.Stdout).Encode(hookOutput{SuppressOutput: true})
+	}()
+
+	if err := indexHook(stdin, pruneOlderThan, jobs); err != nil {
+		fmt.Fprintf(os.Stderr, "cidx-index: %v\n", err)
+	}
+}
+
+// indexHook indexes the session named by hook input read from r
+func indexHook(r io.Reader, pruneOlderThan string, jobs int) error {
+	var input hookInput
+	if err := json.NewDecoder(r).Decode(&input); err != nil {
+		return fmt.Errorf("failed to read hook input: %w", err)
+	}
+
+	path, err := transcriptPath(input)
+	if err != nil {
+		return err
+	}
+
+	// A running watcher indexes the change itself
+	if watching() {
+		return nil
+	}
+
+	// Another indexer is running; it or the next hook picks the change up
+	lock, err := shared.AcquireLock(shared.LockPath, hookLockWait)
+	if errors.Is(err, shared.ErrLocked) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	idx, database, err := setupIndexer(pruneOlderThan, jobs)
+	if err != nil {
+		return err
+	}
+	defer database.Close()
+
+	return idx.IndexSession(path)
+}
+
+// transcriptPath returns the transcript a hook is for, working it out from
+// the session and working directory if the input doesn't give it
+func transcriptPath(input hookInput) (string, error) {
+	path := input.TranscriptPath
+	if path == "" && input.SessionID != "" && input.CWD != "" {
+		path = filepath.Join(shared.ProjectsDir, shared.EncodeProjectPath(input.CWD), input.SessionID+".jsonl")
+	}
+	if path == "" {
+		return "", errors.New("hook input has no transcript_path")
+	}
+
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		path = filepath.Join(os.Getenv("HOME"), rest)
+	}
+	return path, nil
+}
+
+// watching reports whether cidx-index --watch is running
+func watching() bool {
+	lock, err := shared.AcquireLock(shared.WatchLockPath, 0)
+	if err != nil {
+		return errors.Is(err, shared.ErrLocked)
+	}
+	lock.Release()
+	return false
+}
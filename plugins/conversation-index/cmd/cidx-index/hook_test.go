@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/shared"
+)
+
+// useTempClaudeDir points the shared paths at a temporary directory for the
+// rest of the test
+func useTempClaudeDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	paths := map[*string]string{
+		&shared.ClaudeDir:     dir,
+		&shared.ProjectsDir:   filepath.Join(dir, "projects"),
+		&shared.DBPath:        filepath.Join(dir, "conversation-index.db"),
+		&shared.ConfigPath:    filepath.Join(dir, "conversation-index.json"),
+		&shared.LockPath:      filepath.Join(dir, "conversation-index.lock"),
+		&shared.WatchLockPath: filepath.Join(dir, "conversation-index.watch.lock"),
+	}
+	for ptr, path := range paths {
+		old := *ptr
+		*ptr = path
+		t.Cleanup(func() { *ptr = old })
+	}
+	return dir
+}
+
+// captureStdout returns what fn writes to stdout
+func captureStdout(t *testing.T, fn func()) []byte {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	old := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = old }()
+
+	out := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(r)
+		out <- data
+	}()
+	fn()
+	w.Close()
+	return <-out
+}
+
+func TestRunHook_StdoutIsHookOutput(t *testing.T) {
+	useTempClaudeDir(t)
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	transcript := filepath.Join(shared.ProjectsDir, "-work-app", "session.jsonl")
+	if err := os.MkdirAll(filepath.Dir(transcript), 0755); err != nil {
+		t.Fatalf("failed to create project directory: %v", err)
+	}
+	content := `{"type":"user","uuid":"u1","timestamp":"2026-01-05T10:00:00Z","message":{"content":"Hello"}}` + "\n"
+	if err := os.WriteFile(transcript, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write transcript: %v", err)
+	}
+
+	// Create the database with the default tokenizer, then change it so the
+	// hook rebuilds the search index
+	_, database, err := setupIndexer("", 1)
+	if err != nil {
+		t.Fatalf("failed to set up indexer: %v", err)
+	}
+	database.Close()
+	if err := os.WriteFile(shared.ConfigPath, []byte(`{"tokenizer":{"porter":true}}`), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	input := `{"session_id":"session","transcript_path":"` + transcript + `","cwd":"/work/app"}`
+	out := captureStdout(t, func() {
+		runHook(strings.NewReader(input), "", 1)
+	})
+
+	dec := json.NewDecoder(bytes.NewReader(out))
+	var output hookOutput
+	if err := dec.Decode(&output); err != nil {
+		t.Fatalf("expected hook output on stdout, got %q: %v", out, err)
+	}
+	if !output.SuppressOutput {
+		t.Errorf("expected suppressOutput, got %q", out)
+	}
+	var extra json.RawMessage
+	if err := dec.Decode(&extra); err != io.EOF {
+		t.Errorf("expected only the hook output on stdout, got %q", out)
+	}
+}
+
+func TestRunHook_PrunesExpiredConversations(t *testing.T) {
+	useTempClaudeDir(t)
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	project := filepath.Join(shared.ProjectsDir, "-work-app")
+	if err := os.MkdirAll(project, 0755); err != nil {
+		t.Fatalf("failed to create project directory: %v", err)
+	}
+	writeTranscript := func(name, timestamp string) string {
+		path := filepath.Join(project, name+".jsonl")
+		content := `{"type":"user","uuid":"` + name + `-1","timestamp":"` + timestamp + `","message":{"content":"Hello"}}` + "\n"
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write transcript: %v", err)
+		}
+		return path
+	}
+
+	// Index a month-old conversation without pruning it
+	old := writeTranscript("old", time.Now().AddDate(0, -1, 0).UTC().Format(time.RFC3339))
+	monthAgo := time.Now().AddDate(0, -1, 0)
+	if err := os.Chtimes(old, monthAgo, monthAgo); err != nil {
+		t.Fatalf("failed to age transcript: %v", err)
+	}
+	idx, database, err := setupIndexer("", 1)
+	if err != nil {
+		t.Fatalf("failed to set up indexer: %v", err)
+	}
+	if err := idx.IndexPaths([]string{old}); err != nil {
+		t.Fatalf("failed to index old transcript: %v", err)
+	}
+	database.Close()
+
+	session := writeTranscript("session", time.Now().UTC().Format(time.RFC3339))
+	input := `{"session_id":"session","transcript_path":"` + session + `","cwd":"/work/app"}`
+	captureStdout(t, func() {
+		runHook(strings.NewReader(input), "7d", 1)
+	})
+
+	_, database, err = setupIndexer("", 1)
+	if err != nil {
+		t.Fatalf("failed to open index: %v", err)
+	}
+	defer database.Close()
+	conversations, err := database.ListConversations()
+	if err != nil {
+		t.Fatalf("failed to list conversations: %v", err)
+	}
+	var uuids []string
+	for _, conv := range conversations {
+		uuids = append(uuids, conv.UUID)
+	}
+	if len(uuids) != 1 || uuids[0] != "session" {
+		t.Errorf("expected only the session to remain indexed, got %v", uuids)
+	}
+}
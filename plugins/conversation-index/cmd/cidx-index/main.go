@@ -1,32 +1,68 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"log"
 	"os"
+	"runtime"
+	"time"
 
 	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/db"
 	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/indexer"
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/redact"
 	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/shared"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "maintain" {
+		runMaintain(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "redact" {
+		runRedact(os.Args[2:])
+		return
+	}
+
 	// Parse command line flags
 	fullReindex := flag.Bool("full-reindex", false, "Drop existing index and reindex all conversations")
 	flag.BoolVar(fullReindex, "f", false, "Drop existing index and reindex all conversations (shorthand)")
+	pruneOlderThan := flag.String("prune-older-than", "", "Remove conversations not updated for this long, e.g. 180d")
+	jobs := flag.Int("jobs", runtime.NumCPU(), "Transcripts to read and parse in parallel")
+	flag.IntVar(jobs, "j", runtime.NumCPU(), "Transcripts to read and parse in parallel (shorthand)")
+	watch := flag.Bool("watch", false, "Keep running, indexing transcripts as they change")
+	watchDelay := flag.Duration("watch-delay", time.Second, "How long to collect changes before indexing them with --watch")
+	hook := flag.Bool("hook", false, "Index the transcript named by hook input on stdin")
 
 	flag.Parse()
 
-	// Open database
-	database, err := db.Open(shared.DBPath)
+	if *hook {
+		runHook(os.Stdin, *pruneOlderThan, *jobs)
+		return
+	}
+
+	// The watcher takes the lock for each batch it indexes
+	if !*watch {
+		lock := lockIndex(indexLockWait)
+		defer lock.Release()
+	}
+
+	idx, database, err := setupIndexer(*pruneOlderThan, *jobs)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 	defer database.Close()
 
-	// Create indexer
-	idx := indexer.NewIndexer(database, shared.ProjectsDir)
+	if *watch {
+		runWatch(idx, *fullReindex, *watchDelay)
+		return
+	}
 
 	// Run indexing
 	if err := idx.IndexAll(*fullReindex); err != nil {
@@ -34,3 +70,179 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// setupIndexer opens the database, brings its schema and search index up to
+// date with the config, and creates an indexer for it
+func setupIndexer(pruneOlderThan string, jobs int) (*indexer.Indexer, db.DB, error) {
+	database, err := db.Open(shared.DBPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	idx, err := configureIndexer(database, pruneOlderThan, jobs)
+	if err != nil {
+		database.Close()
+		return nil, nil, err
+	}
+	return idx, database, nil
+}
+
+// configureIndexer applies the config to an open database and creates an
+// indexer for it
+func configureIndexer(database db.DB, pruneOlderThan string, jobs int) (*indexer.Indexer, error) {
+	if err := database.InitSchema(); err != nil {
+		return nil, fmt.Errorf("failed to migrate database: %w", err)
+	}
+
+	cfg, err := shared.LoadConfig(shared.ConfigPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Rebuild the search index from stored messages if the tokenizer changed
+	rebuilt, err := database.SetTokenizer(tokenizer(cfg.Tokenizer))
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure tokenizer: %w", err)
+	}
+	if rebuilt {
+		spec, _ := database.Tokenizer()
+		log.Printf("Rebuilt search index with tokenizer: %s", spec)
+	}
+
+	retention, err := retentionPolicy(cfg.Retention, pruneOlderThan)
+	if err != nil {
+		return nil, err
+	}
+
+	redactor, err := redact.FromConfig(cfg.Redaction)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure redaction: %w", err)
+	}
+
+	return indexer.NewIndexerWithOptions(database, shared.ProjectsDir, indexer.IndexerOptions{
+		Parser: indexer.ParserOptions{
+			ToolResults:        cfg.Indexing.ToolResults,
+			MaxToolResultBytes: cfg.Indexing.MaxToolResultBytes,
+			Thinking:           cfg.Indexing.Thinking,
+		},
+		Retention: retention,
+		Redactor:  redactor,
+		Jobs:      jobs,
+	}), nil
+}
+
+// runMigrate implements `cidx-index migrate [--dry-run]`
+func runMigrate(args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Show pending migrations without applying them")
+	fs.Parse(args)
+
+	database := openDatabase()
+	defer database.Close()
+
+	version, err := database.SchemaVersion()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading schema version: %v\n", err)
+		os.Exit(1)
+	}
+
+	pending, err := database.PendingMigrations()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing migrations: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Schema version: %d (latest: %d)\n", version, db.LatestSchemaVersion())
+	if len(pending) == 0 {
+		fmt.Println("Database is up to date.")
+		return
+	}
+
+	if *dryRun {
+		fmt.Printf("%d pending migration(s):\n", len(pending))
+		for _, m := range pending {
+			fmt.Printf("\n%d. %s\n", m.Version, m.Description)
+			for _, stmt := range m.Statements {
+				fmt.Printf("   %s;\n", shared.CompactWhitespace(stmt))
+			}
+		}
+		return
+	}
+
+	applied, err := database.Migrate()
+	for _, m := range applied {
+		fmt.Printf("Applied %d. %s\n", m.Version, m.Description)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error migrating database: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// retentionPolicy applies the retention config, with pruneOlderThan
+// overriding its default
+func retentionPolicy(cfg shared.RetentionConfig, pruneOlderThan string) (indexer.RetentionPolicy, error) {
+	policy := indexer.RetentionPolicy{Projects: make(map[string]time.Duration)}
+
+	if pruneOlderThan == "" {
+		pruneOlderThan = cfg.PruneOlderThan
+	}
+	if pruneOlderThan != "" {
+		age, err := shared.ParseDuration(pruneOlderThan)
+		if err != nil {
+			return policy, fmt.Errorf("invalid retention period %q: %w", pruneOlderThan, err)
+		}
+		policy.MaxAge = age
+	}
+
+	for project, value := range cfg.Projects {
+		age, err := shared.ParseDuration(value)
+		if err != nil {
+			return policy, fmt.Errorf("invalid retention period %q for %s: %w", value, project, err)
+		}
+		policy.Projects[project] = age
+	}
+
+	return policy, nil
+}
+
+// tokenizer applies the tokenizer config on top of the default tokenizer
+func tokenizer(cfg shared.TokenizerConfig) db.Tokenizer {
+	t := db.DefaultTokenizer()
+	t.Porter = cfg.Porter
+	t.TokenChars = cfg.TokenChars
+	if cfg.RemoveDiacritics != nil {
+		t.RemoveDiacritics = *cfg.RemoveDiacritics
+	}
+	return t
+}
+
+// indexLockWait is how long indexing waits for another indexer, or a
+// maintenance run, to finish
+const indexLockWait = 30 * time.Second
+
+// lockIndex takes the lock cidx-index processes share while writing the
+// index (hook, watch, maintain and redact), waiting up to wait for another
+// to finish, or exits
+func lockIndex(wait time.Duration) *shared.Lock {
+	lock, err := shared.AcquireLock(shared.LockPath, wait)
+	if errors.Is(err, shared.ErrLocked) {
+		fmt.Fprintf(os.Stderr, "Error: indexing is still running after %s, try again later\n", wait)
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	return lock
+}
+
+// openDatabase opens the index database or exits
+func openDatabase() db.DB {
+	database, err := db.Open(shared.DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	return database
+}
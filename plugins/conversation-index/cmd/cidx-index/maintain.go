@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/db"
+)
+
+// runMaintain implements `cidx-index maintain [--wait <duration>]`. It holds
+// the indexing lock throughout, so hooks skip indexing until it's done.
+func runMaintain(args []string) {
+	fs := flag.NewFlagSet("maintain", flag.ExitOnError)
+	wait := fs.Duration("wait", 30*time.Second, "How long to wait for a running indexer to finish")
+	fs.Parse(args)
+
+	lock := lockIndex(*wait)
+	defer lock.Release()
+
+	database := openDatabase()
+	defer database.Close()
+
+	if err := database.InitSchema(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error migrating database: %v\n", err)
+		os.Exit(1)
+	}
+
+	report, err := database.Maintain()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error maintaining database: %v\n", err)
+		os.Exit(1)
+	}
+	printMaintenanceReport(report)
+}
+
+// printMaintenanceReport summarizes what maintenance checked and fixed
+func printMaintenanceReport(report *db.MaintenanceReport) {
+	if len(report.IndexProblems) == 0 {
+		fmt.Println("Search indexes: ok")
+	} else {
+		fmt.Println("Search indexes: rebuilt after failed checks")
+		for _, problem := range report.IndexProblems {
+			fmt.Printf("  %s\n", problem)
+		}
+	}
+	if report.CountsFixed == 0 {
+		fmt.Println("Message counts: ok")
+	} else {
+		fmt.Printf("Message counts: fixed %d conversation(s)\n", report.CountsFixed)
+	}
+	fmt.Printf("Database size: %s -> %s\n", formatBytes(report.SizeBefore), formatBytes(report.SizeAfter))
+}
+
+// formatBytes formats a size in bytes with a binary unit
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
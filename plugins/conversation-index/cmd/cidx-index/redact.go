@@ -0,0 +1,60 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/redact"
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/shared"
+)
+
+// runRedact implements `cidx-index redact [--wait <duration>]`. It applies
+// the current redaction rules to everything already indexed, then compacts
+// the database so the replaced text doesn't linger in free pages.
+func runRedact(args []string) {
+	fs := flag.NewFlagSet("redact", flag.ExitOnError)
+	wait := fs.Duration("wait", 30*time.Second, "How long to wait for a running indexer to finish")
+	fs.Parse(args)
+
+	cfg, err := shared.LoadConfig(shared.ConfigPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	redactor, err := redact.FromConfig(cfg.Redaction)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error configuring redaction: %v\n", err)
+		os.Exit(1)
+	}
+	if redactor == nil {
+		fmt.Fprintln(os.Stderr, "Error: redaction is disabled in the config")
+		os.Exit(1)
+	}
+
+	lock := lockIndex(*wait)
+	defer lock.Release()
+
+	database := openDatabase()
+	defer database.Close()
+
+	if err := database.InitSchema(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error migrating database: %v\n", err)
+		os.Exit(1)
+	}
+
+	changed, err := database.RewriteContent(redactor.Redact)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error redacting: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Redacted %d stored value(s)\n", changed)
+
+	report, err := database.Maintain()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error maintaining database: %v\n", err)
+		os.Exit(1)
+	}
+	printMaintenanceReport(report)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/indexer"
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/shared"
+)
+
+// runWatch implements `cidx-index --watch`: it catches up on transcripts
+// written since the last run, then indexes them as they change until it is
+// interrupted. While it runs, the hook scripts leave indexing to it.
+func runWatch(idx *indexer.Indexer, fullReindex bool, delay time.Duration) {
+	watchLock, err := shared.AcquireLock(shared.WatchLockPath, 0)
+	if errors.Is(err, shared.ErrLocked) {
+		fmt.Fprintln(os.Stderr, "Error: another cidx-index --watch is already running")
+		os.Exit(1)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer watchLock.Release()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Watch before catching up, so nothing written meanwhile is missed
+	watcher, err := indexer.NewWatcher(shared.ProjectsDir, delay)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error watching %s: %v\n", shared.ProjectsDir, err)
+		os.Exit(1)
+	}
+	defer watcher.Close()
+
+	if err := withIndexLock(func() error { return idx.IndexAll(fullReindex) }); err != nil {
+		fmt.Fprintf(os.Stderr, "Error indexing conversations: %v\n", err)
+		os.Exit(1)
+	}
+
+	log.Printf("Watching %s for changes", shared.ProjectsDir)
+	err = watcher.Run(ctx, func(paths []string) error {
+		return withIndexLock(func() error { return idx.IndexPaths(paths) })
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error watching %s: %v\n", shared.ProjectsDir, err)
+		os.Exit(1)
+	}
+	log.Println("Stopped watching")
+}
+
+// withIndexLock runs fn holding the indexing lock. If another indexer holds
+// it for longer than indexLockWait, the batch is retried with the next one.
+func withIndexLock(fn func() error) error {
+	lock, err := shared.AcquireLock(shared.LockPath, indexLockWait)
+	if err != nil {
+		return err
+	}
+	defer lock.Release()
+
+	return fn()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/db"
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/shared"
+)
+
+// runFiles implements `cidx-search files [options] <path>`
+func runFiles(args []string) {
+	fs := flag.NewFlagSet("files", flag.ExitOnError)
+	project := fs.String("project", "", "Only list conversations in this project")
+	limit := fs.Int("limit", 100, "Maximum conversations")
+	jsonOutput := fs.Bool("json", false, "Output as JSON")
+	fs.Usage = func() {
+		fmt.Println(`Usage: search files [options] <path>
+
+Lists conversations whose tool calls read, edited or wrote a file, or any
+file beneath a directory, most recently touched first. Relative paths are
+resolved against the current directory.
+
+Options:
+  --project <path>     Only list conversations in this project
+  --limit <number>     Maximum conversations (default: 100)
+  --json               Output as JSON
+
+Examples:
+  search files internal/db/db.go
+  search files --json /Users/doug/code/app/internal`)
+	}
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(0)
+	}
+
+	// Tool calls record absolute paths
+	path, err := filepath.Abs(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving path: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := db.FileOptions{Path: path, Limit: *limit}
+	if *project != "" {
+		opts.ProjectPath = shared.EncodeProjectPath(*project)
+	}
+
+	database, err := db.Open(shared.DBPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening database: %v\n", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	activities, err := database.FilesTouched(opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing conversations: %v\n", err)
+		os.Exit(1)
+	}
+
+	result := &db.FilesResult{Path: path, Conversations: activities}
+	if *jsonOutput {
+		writeJSON(result)
+	} else {
+		printFileResults(result)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"time"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/shared"
+)
+
+// listFlag collects a flag that may be repeated or given as a comma-separated list
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// parseDateFlag parses a --since/--until value, with relative ages measured
+// back from now. A bare calendar date given as an upper bound covers that
+// whole day.
+func parseDateFlag(value string, upperBound bool, now time.Time) (time.Time, error) {
+	t, err := shared.ParseDate(value, now)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if upperBound {
+		if _, err := time.Parse(shared.DateOnly, strings.TrimSpace(value)); err == nil {
+			t = t.AddDate(0, 0, 1)
+		}
+	}
+
+	return t, nil
+}
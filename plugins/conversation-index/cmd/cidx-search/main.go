@@ -1,10 +1,10 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/db"
@@ -12,11 +12,36 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "files" {
+		runFiles(os.Args[2:])
+		return
+	}
+
 	// Parse command line flags
 	scope := flag.String("scope", "current_project", "Search scope: current_project or all_projects")
 	project := flag.String("project", "", "Current project path (default: cwd)")
 	limit := flag.Int("limit", 100, "Maximum results")
+	offset := flag.Int("offset", 0, "Results to skip")
+	cursor := flag.String("cursor", "", "Cursor from a previous page of results")
 	jsonOutput := flag.Bool("json", false, "Output as JSON")
+	raw := flag.Bool("raw", false, "Pass the query to SQLite FTS5 unparsed")
+	substring := flag.Bool("substring", false, "Match terms anywhere within words, e.g. parts of identifiers and paths")
+	messages := flag.Bool("messages", false, "Return individual matching messages instead of conversations")
+	noColor := flag.Bool("no-color", false, "Disable highlighting in text output")
+	since := flag.String("since", "", "Only match messages on or after this date")
+	until := flag.String("until", "", "Only match messages before this date")
+	minMessages := flag.Int("min-messages", 0, "Minimum messages in a conversation")
+	maxMessages := flag.Int("max-messages", 0, "Maximum messages in a conversation")
+	halfLife := flag.String("recency-half-life", "", "Halve scores of conversations this old, e.g. 90d")
+	errorsOnly := flag.Bool("errors", false, "Only match tool results that reported an error")
+	includeAbandoned := flag.Bool("include-abandoned", false, "Also match edited prompts and retried answers off the active branch")
+	hideSidechainOnly := flag.Bool("hide-sidechain-only", false, "Leave out conversations matched only by their subagents")
+	excerpts := flag.Int("excerpts", db.DefaultExcerptsPerMatch, "Excerpts per match (0 for none)")
+	var roles, branches, models, exclude listFlag
+	flag.Var(&roles, "role", "Only match messages with this role (user, assistant, thinking, tool, tool_result, title)")
+	flag.Var(&branches, "branch", "Only match conversations on this git branch")
+	flag.Var(&models, "model", "Only match conversations using a model containing this text, e.g. opus")
+	flag.Var(&exclude, "exclude", "Conversation UUID to leave out of the results")
 	help := flag.Bool("help", false, "Show help")
 	flag.BoolVar(help, "h", false, "Show help (shorthand)")
 
@@ -25,22 +50,133 @@ func main() {
 	// Show help
 	if *help || flag.NArg() == 0 {
 		fmt.Println(`Usage: search [options] <query>
+       search files [options] <path>
 
 Options:
   --scope <current_project|all_projects>  Search scope (default: current_project)
   --project <path>                         Current project path for scoping
-  --limit <number>                         Maximum results (default: 100)
+  --limit <number>                         Maximum results per page (default: 100)
+  --offset <number>                        Results to skip
+  --cursor <cursor>                        Fetch the next page, repeating the same query and options
   --json                                   Output as JSON
+  --messages                               Return matching messages with transcript line numbers
+  --substring                              Match partial identifiers and paths (3+ characters)
+  --raw                                    Use raw SQLite FTS5 query syntax
+  --no-color                               Disable highlighting in text output
+  --since <date>                           Only match messages on or after date
+  --until <date>                           Only match messages before date
+  --role <role>                            Only match messages with role: user, assistant,
+                                           thinking, tool, tool_result or title (repeatable)
+  --errors                                 Only match tool results that reported an error
+  --include-abandoned                      Also match edited prompts and retries off the active branch
+  --hide-sidechain-only                    Leave out conversations matched only by their subagents
+  --branch <name>                          Only match conversations on git branch (repeatable)
+  --model <name>                           Only match conversations using a model, e.g. opus (repeatable)
+  --min-messages <number>                  Minimum messages in a conversation
+  --max-messages <number>                  Maximum messages in a conversation
+  --exclude <uuid>                         Leave a conversation out (repeatable)
+  --excerpts <number>                      Excerpts per match (default: 3, 0 for none)
+  --recency-half-life <duration>           Favour recent conversations, e.g. 90d
+
+Ranking weights can also be set in ~/.claude/conversation-index.json:
+  {"ranking": {"content_weight": 1.0,
+               "role_weights": {"user": 1.5, "assistant": 1.0, "thinking": 0.4,
+                                "tool": 0.5, "tool_result": 0.3, "title": 3.0},
+               "recency_half_life": "90d"}}
+
+Query syntax:
+  zeebe worker          both terms          "exact phrase"     phrase
+  zeebe OR worker       either term         zeeb*              prefix
+  -rabbitmq             exclude a term      project:<text>     project path contains
+  branch:<name>         git branch          model:<name>       model used
+  role:<role>           message role        tool:<name>        tool calls of a tool
+  file:<path>           mentions a file     after:/before:<date>
+Punctuation is literal, so claude-marketplace, foo.go and C++ search as typed.
+
+Dates are YYYY-MM-DD, an RFC 3339 timestamp, or an age like 12h, 7d or 2w.
 
 Examples:
   search "authentication system"
   search --scope all_projects "bug fix"
-  search --project "/Users/doug/code/app" "API"`)
+  search --project "/Users/doug/code/app" "API"
+  search --since 7d --role user "migration"
+  search --messages --limit 10 "panic: runtime error"
+  search --substring "IndexConv"
+  search --errors "undefined: foo"
+  search --scope all_projects --branch feature/x "migration"
+  search "tool:Bash kubectl after:7d"
+  search --raw "NEAR(zeebe worker, 5)"
+  search files internal/db/db.go`)
 		os.Exit(0)
 	}
 
 	query := flag.Arg(0)
 
+	opts := db.SearchOptions{
+		Query:             query,
+		Raw:               *raw,
+		Substring:         *substring,
+		Scope:             *scope,
+		Limit:             *limit,
+		Offset:            *offset,
+		Cursor:            *cursor,
+		Now:               time.Now(),
+		Excerpts:          *excerpts,
+		Roles:             roles,
+		MinMessages:       *minMessages,
+		MaxMessages:       *maxMessages,
+		Branches:          branches,
+		Models:            models,
+		ExcludeUUIDs:      exclude,
+		ErrorsOnly:        *errorsOnly,
+		IncludeAbandoned:  *includeAbandoned,
+		HideSidechainOnly: *hideSidechainOnly,
+	}
+	if opts.Excerpts == 0 {
+		opts.Excerpts = -1
+	}
+
+	for _, role := range roles {
+		if !db.IsValidRole(role) {
+			fmt.Fprintf(os.Stderr, "Error: unknown role %q (valid roles: %s)\n", role, strings.Join(db.Roles, ", "))
+			os.Exit(1)
+		}
+	}
+
+	cfg, err := shared.LoadConfig(shared.ConfigPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	weights, err := rankingWeights(cfg.Ranking, *halfLife)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in ranking config: %v\n", err)
+		os.Exit(1)
+	}
+	opts.Weights = &weights
+
+	// Later pages resolve relative dates from the time of the first page
+	if *cursor != "" {
+		if opts.Now, err = db.CursorTime(*cursor); err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing --cursor: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *since != "" {
+		if opts.Since, err = parseDateFlag(*since, false, opts.Now); err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing --since: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *until != "" {
+		if opts.Until, err = parseDateFlag(*until, true, opts.Now); err != nil {
+			fmt.Fprintf(os.Stderr, "Error parsing --until: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
 	// Default project to current working directory
 	if *project == "" {
 		cwd, err := os.Getwd()
@@ -53,6 +189,7 @@ Examples:
 		// Encode the project path for database lookup
 		*project = shared.EncodeProjectPath(*project)
 	}
+	opts.ProjectPath = *project
 
 	// Open database
 	database, err := db.Open(shared.DBPath)
@@ -62,8 +199,33 @@ Examples:
 	}
 	defer database.Close()
 
+	params := searchParams(opts)
+	color := useColor(*noColor)
+
+	// Message mode returns individual messages instead of conversations
+	if *messages {
+		page, err := database.SearchMessages(opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error searching: %v\n", err)
+			os.Exit(1)
+		}
+
+		result := &db.MessageSearchResult{
+			SearchParams: params,
+			PageInfo:     page.PageInfo,
+			Messages:     page.Messages,
+			Suggestions:  page.Suggestions,
+		}
+		if *jsonOutput {
+			writeJSON(result)
+		} else {
+			printMessageResults(result, color)
+		}
+		return
+	}
+
 	// Execute search
-	matches, err := database.Search(query, *scope, *project, *limit)
+	page, err := database.Search(opts)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error searching: %v\n", err)
 		os.Exit(1)
@@ -71,48 +233,68 @@ Examples:
 
 	// Build result
 	result := &db.SearchResult{
-		Query:          query,
-		Scope:          *scope,
-		CurrentProject: *project,
-		TotalMatches:   len(matches),
-		Matches:        matches,
+		SearchParams: params,
+		PageInfo:     page.PageInfo,
+		Matches:      page.Matches,
+		Suggestions:  page.Suggestions,
 	}
 
 	// Output results
 	if *jsonOutput {
-		encoder := json.NewEncoder(os.Stdout)
-		encoder.SetIndent("", "  ")
-		if err := encoder.Encode(result); err != nil {
-			fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
-			os.Exit(1)
-		}
+		writeJSON(result)
 	} else {
-		printResults(result)
+		printResults(result, color)
 	}
 }
 
-func printResults(result *db.SearchResult) {
-	fmt.Printf("Found %d conversation(s) matching \"%s\"\n\n", result.TotalMatches, result.Query)
-
-	if len(result.Matches) == 0 {
-		fmt.Println("No matches found.")
-		return
+// searchParams echoes the search options back in the response
+func searchParams(opts db.SearchOptions) db.SearchParams {
+	params := db.SearchParams{
+		Query:             opts.Query,
+		Scope:             opts.Scope,
+		Substring:         opts.Substring,
+		CurrentProject:    opts.ProjectPath,
+		Roles:             opts.Roles,
+		MinMessages:       opts.MinMessages,
+		MaxMessages:       opts.MaxMessages,
+		Branches:          opts.Branches,
+		Models:            opts.Models,
+		Exclude:           opts.ExcludeUUIDs,
+		ErrorsOnly:        opts.ErrorsOnly,
+		IncludeAbandoned:  opts.IncludeAbandoned,
+		HideSidechainOnly: opts.HideSidechainOnly,
+	}
+	if !opts.Since.IsZero() {
+		params.Since = shared.FormatTimestamp(opts.Since.UTC())
 	}
+	if !opts.Until.IsZero() {
+		params.Until = shared.FormatTimestamp(opts.Until.UTC())
+	}
+	return params
+}
 
-	for i, match := range result.Matches {
-		fmt.Printf("%d. UUID: %s\n", i+1, match.UUID)
-		fmt.Printf("   Project: %s\n", match.ProjectPath)
+// rankingWeights applies config overrides and the --recency-half-life flag
+// on top of the default ranking weights
+func rankingWeights(cfg shared.RankingConfig, halfLife string) (db.RankingWeights, error) {
+	weights := db.DefaultRankingWeights()
 
-		// Parse and format created timestamp
-		createdAt, err := time.Parse(time.RFC3339, match.CreatedAt)
-		if err == nil {
-			fmt.Printf("   Created: %s\n", createdAt.Format("Jan 2, 2006 at 3:04 PM"))
-		} else {
-			fmt.Printf("   Created: %s\n", match.CreatedAt)
-		}
+	if cfg.ContentWeight > 0 {
+		weights.Content = cfg.ContentWeight
+	}
+	for role, weight := range cfg.RoleWeights {
+		weights.Roles[role] = weight
+	}
 
-		fmt.Printf("   Messages: %d\n", match.MessageCount)
-		fmt.Printf("   Summary: %s\n", match.Summary)
-		fmt.Printf("   Relevance: %.2f\n\n", match.RelevanceScore)
+	if halfLife == "" {
+		halfLife = cfg.RecencyHalfLife
 	}
+	if halfLife != "" {
+		d, err := shared.ParseDuration(halfLife)
+		if err != nil {
+			return weights, fmt.Errorf("invalid recency half life: %w", err)
+		}
+		weights.RecencyHalfLife = d
+	}
+
+	return weights, nil
 }
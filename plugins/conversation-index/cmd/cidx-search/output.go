@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/db"
+)
+
+// ANSI escapes used to highlight matched terms in excerpts
+const (
+	ansiHighlight = "\x1b[1;33m"
+	ansiReset     = "\x1b[0m"
+)
+
+// useColor reports whether text output should use terminal highlighting
+func useColor(disabled bool) bool {
+	if disabled || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// renderExcerpt marks the highlighted terms of an excerpt, using ANSI colors
+// on a terminal and **bold** markers otherwise
+func renderExcerpt(excerpt db.Excerpt, color bool) string {
+	open, close := "**", "**"
+	if color {
+		open, close = ansiHighlight, ansiReset
+	}
+
+	var b strings.Builder
+	pos := 0
+	for _, h := range excerpt.Highlights {
+		if h.Start < pos || h.End > len(excerpt.Text) {
+			continue
+		}
+		b.WriteString(excerpt.Text[pos:h.Start])
+		b.WriteString(open)
+		b.WriteString(excerpt.Text[h.Start:h.End])
+		b.WriteString(close)
+		pos = h.End
+	}
+	b.WriteString(excerpt.Text[pos:])
+
+	return b.String()
+}
+
+func printResults(result *db.SearchResult, color bool) {
+	fmt.Printf("Found %d conversation(s) matching \"%s\"\n\n", result.TotalMatches, result.Query)
+
+	if len(result.Matches) == 0 {
+		fmt.Println("No matches found.")
+		printSuggestions(result.Suggestions)
+		return
+	}
+
+	for i, match := range result.Matches {
+		fmt.Printf("%d. UUID: %s\n", result.Offset+i+1, match.UUID)
+		fmt.Printf("   Project: %s\n", match.ProjectPath)
+
+		// Parse and format created timestamp
+		createdAt, err := time.Parse(time.RFC3339, match.CreatedAt)
+		if err == nil {
+			fmt.Printf("   Created: %s\n", createdAt.Format("Jan 2, 2006 at 3:04 PM"))
+		} else {
+			fmt.Printf("   Created: %s\n", match.CreatedAt)
+		}
+
+		if match.GitBranch != "" {
+			fmt.Printf("   Branch: %s\n", match.GitBranch)
+		}
+		fmt.Printf("   Messages: %d\n", match.MessageCount)
+		if match.Title != "" {
+			fmt.Printf("   Title: %s\n", match.Title)
+		} else {
+			fmt.Printf("   Summary: %s\n", match.Summary)
+		}
+		if match.SidechainHits > 0 {
+			fmt.Printf("   Relevance: %.2f (%d matching messages, %d in subagents)\n", match.RelevanceScore, match.Hits, match.SidechainHits)
+		} else {
+			fmt.Printf("   Relevance: %.2f (%d matching messages)\n", match.RelevanceScore, match.Hits)
+		}
+
+		for _, excerpt := range match.Excerpts {
+			fmt.Printf("   > [%s, %s] %s\n", excerptRole(excerpt), formatExcerptTime(excerpt.Timestamp), renderExcerpt(excerpt, color))
+		}
+		fmt.Println()
+	}
+
+	printNextPage(result.PageInfo, len(result.Matches))
+}
+
+func printMessageResults(result *db.MessageSearchResult, color bool) {
+	fmt.Printf("Found %d message(s) matching \"%s\"\n\n", result.TotalMatches, result.Query)
+
+	if len(result.Messages) == 0 {
+		fmt.Println("No matches found.")
+		printSuggestions(result.Suggestions)
+		return
+	}
+
+	for i, match := range result.Messages {
+		fmt.Printf("%d. [%s, %s] %s\n", result.Offset+i+1, excerptRole(match.Excerpt), formatExcerptTime(match.Timestamp), renderExcerpt(match.Excerpt, color))
+		fmt.Printf("   Conversation: %s\n", match.ConversationUUID)
+		if match.ParentUUID != "" {
+			fmt.Printf("   Started by: %s\n", match.ParentUUID)
+		}
+		fmt.Printf("   Project: %s\n", match.ProjectPath)
+		if match.TranscriptPath != "" {
+			fmt.Printf("   Transcript: %s:%d\n", match.TranscriptPath, match.Line)
+		} else {
+			fmt.Printf("   Line: %d\n", match.Line)
+		}
+		fmt.Println()
+	}
+
+	printNextPage(result.PageInfo, len(result.Messages))
+}
+
+func printFileResults(result *db.FilesResult) {
+	fmt.Printf("Found %d conversation(s) that touched %s\n\n", len(result.Conversations), result.Path)
+
+	if len(result.Conversations) == 0 {
+		fmt.Println("No matches found.")
+		return
+	}
+
+	for i, activity := range result.Conversations {
+		fmt.Printf("%d. UUID: %s\n", i+1, activity.UUID)
+		fmt.Printf("   Project: %s\n", activity.ProjectPath)
+		if activity.Title != "" {
+			fmt.Printf("   Title: %s\n", activity.Title)
+		}
+		if activity.TranscriptPath != "" {
+			fmt.Printf("   Transcript: %s\n", activity.TranscriptPath)
+		}
+		for _, touch := range activity.Touches {
+			fmt.Printf("   > [%s, line %d] %s %s\n", formatExcerptTime(touch.Timestamp), touch.Line, touch.Tool, touch.FilePath)
+		}
+		fmt.Println()
+	}
+}
+
+// printSuggestions lists corrected queries for a search without matches
+func printSuggestions(suggestions []db.Suggestion) {
+	if len(suggestions) == 0 {
+		return
+	}
+	fmt.Println("\nDid you mean:")
+	for _, s := range suggestions {
+		fmt.Printf("  %s  (%q in %d messages)\n", s.Query, s.Replacement, s.Documents)
+	}
+}
+
+// printNextPage tells the user how to fetch the rest of a partial result set
+func printNextPage(page db.PageInfo, n int) {
+	if !page.HasMore {
+		return
+	}
+	fmt.Printf("Showing %d-%d of %d. For the next page, repeat the search with:\n", page.Offset+1, page.Offset+n, page.TotalMatches)
+	fmt.Printf("  --cursor %s\n", page.NextCursor)
+}
+
+// excerptRole labels an excerpt with its role, noting tool errors,
+// subagents and abandoned branches
+func excerptRole(excerpt db.Excerpt) string {
+	label := excerpt.Role
+	if excerpt.IsError {
+		label += " error"
+	}
+	if excerpt.Subagent != "" {
+		label += ", subagent " + excerpt.Subagent
+	} else if excerpt.Sidechain {
+		label += ", subagent"
+	}
+	if excerpt.Abandoned {
+		label += ", abandoned"
+	}
+	return label
+}
+
+// formatExcerptTime formats an excerpt timestamp for text output
+func formatExcerptTime(timestamp string) string {
+	if ts, err := time.Parse(time.RFC3339, timestamp); err == nil {
+		return ts.Format("Jan 2, 2006 3:04 PM")
+	}
+	return timestamp
+}
+
+// writeJSON prints v as indented JSON or exits
+func writeJSON(v interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(v); err != nil {
+		fmt.Fprintf(os.Stderr, "Error encoding JSON: %v\n", err)
+		os.Exit(1)
+	}
+}
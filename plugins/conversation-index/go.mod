@@ -2,7 +2,10 @@ module github.com/doughughes/claude-marketplace/plugins/conversation-index
 
 go 1.25.4
 
-require modernc.org/sqlite v1.34.4
+require (
+	github.com/fsnotify/fsnotify v1.10.1
+	modernc.org/sqlite v1.34.4
+)
 
 require (
 	github.com/dustin/go-humanize v1.0.1 // indirect
@@ -0,0 +1,79 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// execer is what writes need from *sql.DB and *sql.Tx, so they can run
+// alone or as part of a larger transaction
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+	Prepare(query string) (*sql.Stmt, error)
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// inTx runs fn in a transaction, committing if it succeeds
+func (db *sqliteDB) inTx(fn func(tx *sql.Tx) error) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// SaveConversations writes the results of indexing several transcripts in
+// one transaction. Either all of them are saved or none are.
+func (db *sqliteDB) SaveConversations(updates []ConversationUpdate) error {
+	return db.inTx(func(tx *sql.Tx) error {
+		for _, u := range updates {
+			if err := saveUpdate(tx, u); err != nil {
+				return fmt.Errorf("failed to save conversation %s: %w", u.State.ConversationUUID, err)
+			}
+		}
+		return nil
+	})
+}
+
+// saveUpdate applies one ConversationUpdate in the order indexing needs:
+// pruning before the entries are saved again, and the active branch and
+// index state once everything else is in place
+func saveUpdate(q execer, u ConversationUpdate) error {
+	if u.Conversation == nil {
+		return updateIndexState(q, u.State)
+	}
+	if err := saveConversation(q, u.Conversation); err != nil {
+		return err
+	}
+	if u.Prune {
+		if err := pruneConversation(q, u.Conversation.UUID, u.KeepEntries); err != nil {
+			return err
+		}
+	}
+	if err := saveEntries(q, u.Entries); err != nil {
+		return err
+	}
+	if err := saveMessages(q, u.Messages); err != nil {
+		return err
+	}
+	if err := saveToolCalls(q, u.ToolCalls); err != nil {
+		return err
+	}
+	if err := saveSummaries(q, u); err != nil {
+		return err
+	}
+	if err := updateActiveBranch(q, u.Conversation.UUID); err != nil {
+		return err
+	}
+	return updateIndexState(q, u.State)
+}
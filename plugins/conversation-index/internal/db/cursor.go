@@ -0,0 +1,180 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// PageInfo describes where a page of results sits in the full result set
+type PageInfo struct {
+	TotalMatches int    `json:"total_matches"`
+	Offset       int    `json:"offset"`
+	HasMore      bool   `json:"has_more"`
+	NextCursor   string `json:"next_cursor,omitempty"`
+}
+
+// SearchPage is one page of conversation matches
+type SearchPage struct {
+	PageInfo
+	Matches     []Match
+	Suggestions []Suggestion // Set when nothing matched
+}
+
+// MessagePage is one page of message matches
+type MessagePage struct {
+	PageInfo
+	Messages    []MessageMatch
+	Suggestions []Suggestion // Set when nothing matched
+}
+
+// cursor is the decoded form of an opaque page cursor. It pins later pages
+// to the first page's view of the index: only messages up to Snapshot are
+// searched, recency is measured from each conversation's latest message up
+// to Snapshot, and relative dates are resolved from Now. A page starts
+// after the sort key of the previous page's last result rather than at an
+// offset, so results written to or removed between pages can't shift the
+// rest into or out of view. Full-text scores still move slightly as the
+// index grows, which can only matter for results scored almost the same as
+// a page's last one.
+type cursor struct {
+	Snapshot    int64     `json:"s"`
+	Now         time.Time `json:"t"`
+	Offset      int       `json:"o"` // Results on earlier pages
+	After       *pageKey  `json:"a,omitempty"`
+	Fingerprint string    `json:"f"`
+}
+
+// pageKey is the sort key of the last result on a page: score and uuid for
+// conversations, score and message id for messages
+type pageKey struct {
+	Score float64 `json:"r"`
+	UUID  string  `json:"u,omitempty"`
+	ID    int64   `json:"i,omitempty"`
+}
+
+// encode returns the cursor as an opaque string
+func (c cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor parses a cursor produced by encode
+func decodeCursor(s string) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.Fingerprint == "" || c.Offset < 0 {
+		return c, fmt.Errorf("invalid cursor")
+	}
+	return c, nil
+}
+
+// CursorTime returns the reference time a cursor was created with, so
+// relative dates in repeated search options resolve the same way on every page
+func CursorTime(s string) (time.Time, error) {
+	c, err := decodeCursor(s)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return c.Now, nil
+}
+
+// fingerprint identifies the options that decide which results a search
+// returns and in what order, so a cursor can't be used with another search
+func fingerprint(opts SearchOptions) string {
+	key := struct {
+		Query        string
+		Raw          bool
+		Substring    bool
+		Scope        string
+		ProjectPath  string
+		Since        time.Time
+		Until        time.Time
+		Roles        []string
+		MinMessages  int
+		MaxMessages  int
+		Branches     []string
+		Models       []string
+		ExcludeUUIDs []string
+		ErrorsOnly   bool
+		Abandoned    bool
+		Sidechains   bool
+		Weights      RankingWeights
+	}{
+		opts.Query, opts.Raw, opts.Substring, opts.Scope, opts.ProjectPath,
+		opts.Since.UTC(), opts.Until.UTC(), opts.Roles, opts.MinMessages, opts.MaxMessages,
+		opts.Branches, opts.Models, opts.ExcludeUUIDs, opts.ErrorsOnly, opts.IncludeAbandoned, opts.HideSidechainOnly, rankingWeights(opts),
+	}
+	data, _ := json.Marshal(key)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// startPage resolves the cursor or offset in opts to the position of the
+// page being fetched, taking a new snapshot with latest for a first page.
+// The returned options are pinned to the page's snapshot and time.
+func startPage(opts SearchOptions, latest func() (int64, error)) (SearchOptions, cursor, error) {
+	page := cursor{Fingerprint: fingerprint(opts)}
+
+	if opts.Cursor != "" {
+		c, err := decodeCursor(opts.Cursor)
+		if err != nil {
+			return opts, page, err
+		}
+		if c.Fingerprint != page.Fingerprint {
+			return opts, page, fmt.Errorf("cursor is for a different search")
+		}
+		page = c
+	} else {
+		if opts.Offset < 0 {
+			return opts, page, fmt.Errorf("offset must not be negative")
+		}
+		snapshot, err := latest()
+		if err != nil {
+			return opts, page, err
+		}
+		page.Snapshot = snapshot
+		page.Now = opts.Now
+		if page.Now.IsZero() {
+			page.Now = time.Now()
+		}
+		page.Offset = opts.Offset
+	}
+
+	opts.Now = page.Now
+	opts.snapshot = page.Snapshot
+	return opts, page, nil
+}
+
+// pageInfo describes a page of n results at the position of page. The next
+// page starts after last, the sort key of the page's last result, or at the
+// next offset when last is nil.
+func pageInfo(page cursor, total, n int, more bool, last *pageKey) PageInfo {
+	info := PageInfo{
+		TotalMatches: total,
+		Offset:       page.Offset,
+		HasMore:      more,
+	}
+	if info.HasMore {
+		next := page
+		next.Offset += n
+		next.After = last
+		info.NextCursor = next.encode()
+	}
+	return info
+}
+
+// keysetLimit returns the SQL limit for a page of up to limit results, one
+// more than the page holds so HasMore can be told without counting
+func keysetLimit(limit int) int {
+	if limit <= 0 {
+		return -1
+	}
+	return limit + 1
+}
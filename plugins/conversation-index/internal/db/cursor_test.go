@@ -0,0 +1,213 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// webhookMessages returns n messages mentioning webhook
+func webhookMessages(n int) []Message {
+	messages := make([]Message, n)
+	for i := range messages {
+		messages[i] = Message{Role: "user", Content: "the webhook retries"}
+	}
+	return messages
+}
+
+func TestSearch_Pagination(t *testing.T) {
+	db := seedRankingDB(t, map[string][]Message{
+		"conv-a": webhookMessages(5),
+		"conv-b": webhookMessages(4),
+		"conv-c": webhookMessages(3),
+		"conv-d": webhookMessages(2),
+		"conv-e": webhookMessages(1),
+	}, nil)
+
+	opts := SearchOptions{Query: "webhook", Scope: ScopeAllProjects, Limit: 2}
+	fetch := func(cursor string) *SearchPage {
+		t.Helper()
+		opts := opts
+		opts.Cursor = cursor
+		page, err := db.Search(opts)
+		if err != nil {
+			t.Fatalf("failed to search: %v", err)
+		}
+		return page
+	}
+	uuids := func(page *SearchPage) []string {
+		var uuids []string
+		for _, match := range page.Matches {
+			uuids = append(uuids, match.UUID)
+		}
+		return uuids
+	}
+
+	first := fetch("")
+	if got := uuids(first); !reflect.DeepEqual(got, []string{"conv-a", "conv-b"}) {
+		t.Fatalf("unexpected first page: %v", got)
+	}
+	if first.TotalMatches != 5 || !first.HasMore || first.NextCursor == "" {
+		t.Fatalf("unexpected first page info: %+v", first.PageInfo)
+	}
+
+	// The indexer writes between pages: a new best match and another hit
+	// for the last conversation must not shift the later pages
+	conv := &Conversation{UUID: "conv-z", ProjectPath: "/Users/test/project", EncodedPath: "-Users-test-project", CreatedAt: time.Now(), LastUpdated: time.Now()}
+	if err := db.SaveConversation(conv); err != nil {
+		t.Fatalf("failed to save conversation: %v", err)
+	}
+	for uuid, n := range map[string]int{"conv-z": 10, "conv-e": 5} {
+		messages := webhookMessages(n)
+		for i := range messages {
+			messages[i].ConversationUUID = uuid
+			messages[i].Timestamp = time.Now()
+		}
+		if err := db.SaveMessages(messages); err != nil {
+			t.Fatalf("failed to save messages: %v", err)
+		}
+	}
+
+	second := fetch(first.NextCursor)
+	if got := uuids(second); !reflect.DeepEqual(got, []string{"conv-c", "conv-d"}) {
+		t.Errorf("unexpected second page: %v", got)
+	}
+	if second.TotalMatches != 5 || second.Offset != 2 || !second.HasMore {
+		t.Errorf("unexpected second page info: %+v", second.PageInfo)
+	}
+
+	third := fetch(second.NextCursor)
+	if got := uuids(third); !reflect.DeepEqual(got, []string{"conv-e"}) {
+		t.Errorf("unexpected third page: %v", got)
+	}
+	if third.Matches[0].Hits != 1 {
+		t.Errorf("expected hits from the snapshot only, got %d", third.Matches[0].Hits)
+	}
+	if third.HasMore || third.NextCursor != "" {
+		t.Errorf("expected last page, got %+v", third.PageInfo)
+	}
+
+	// A fresh search sees the new messages
+	fresh := fetch("")
+	if fresh.TotalMatches != 6 || fresh.Matches[0].UUID != "conv-z" {
+		t.Errorf("expected new search to include conv-z first, got %v (total %d)", uuids(fresh), fresh.TotalMatches)
+	}
+
+	// Offsets select a page directly
+	page, err := db.Search(SearchOptions{Query: "webhook", Scope: ScopeAllProjects, Offset: 4})
+	if err != nil {
+		t.Fatalf("failed to search with offset: %v", err)
+	}
+	if got := uuids(page); len(got) != 2 {
+		t.Errorf("expected 2 results after offset 4, got %v", got)
+	}
+	if page.TotalMatches != 6 || page.Offset != 4 || page.HasMore {
+		t.Errorf("unexpected offset page info: %+v", page.PageInfo)
+	}
+
+	// Cursors only continue the search they came from
+	if _, err := db.Search(SearchOptions{Query: "retries", Scope: ScopeAllProjects, Limit: 2, Cursor: first.NextCursor}); err == nil || !strings.Contains(err.Error(), "different search") {
+		t.Errorf("expected error for cursor from another search, got %v", err)
+	}
+	if _, err := db.Search(SearchOptions{Query: "webhook", Scope: ScopeAllProjects, Cursor: "not-a-cursor"}); err == nil {
+		t.Error("expected error for invalid cursor")
+	}
+	if _, err := db.Search(SearchOptions{Query: "webhook", Scope: ScopeAllProjects, Offset: -1}); err == nil {
+		t.Error("expected error for negative offset")
+	}
+}
+
+func TestSearch_PaginationWhileIndexing(t *testing.T) {
+	conversations := map[string][]Message{}
+	for i, uuid := range []string{"conv-a", "conv-b", "conv-c", "conv-d", "conv-e", "conv-f", "conv-g", "conv-h"} {
+		conversations[uuid] = webhookMessages(8 - i)
+	}
+	db := seedRankingDB(t, conversations, nil)
+
+	opts := SearchOptions{Query: "webhook", Scope: ScopeAllProjects, Limit: 2}
+	var seen []string
+	fetch := func() *SearchPage {
+		t.Helper()
+		page, err := db.Search(opts)
+		if err != nil {
+			t.Fatalf("failed to search: %v", err)
+		}
+		for _, match := range page.Matches {
+			seen = append(seen, match.UUID)
+		}
+		opts.Cursor = page.NextCursor
+		return page
+	}
+
+	fetch()
+
+	// Between pages the indexer writes to conversations on either side of
+	// the page boundary and removes one that was already shown and one
+	// that wasn't
+	for _, uuid := range []string{"conv-a", "conv-f"} {
+		messages := webhookMessages(3)
+		for i := range messages {
+			messages[i].ConversationUUID = uuid
+			messages[i].Timestamp = time.Now().Add(time.Hour)
+		}
+		if err := db.SaveMessages(messages); err != nil {
+			t.Fatalf("failed to save messages: %v", err)
+		}
+	}
+	for _, uuid := range []string{"conv-b", "conv-d"} {
+		if err := db.RemoveConversation(uuid); err != nil {
+			t.Fatalf("failed to remove conversation: %v", err)
+		}
+	}
+
+	for page := fetch(); page.HasMore; {
+		page = fetch()
+	}
+
+	want := []string{"conv-a", "conv-b", "conv-c", "conv-e", "conv-f", "conv-g", "conv-h"}
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("expected every remaining result once in the first page's order, got %v", seen)
+	}
+}
+
+func TestSearchMessages_Pagination(t *testing.T) {
+	db := seedRankingDB(t, map[string][]Message{
+		"conv-a": webhookMessages(3),
+		"conv-b": webhookMessages(2),
+	}, nil)
+
+	opts := SearchOptions{Query: "webhook", Scope: ScopeAllProjects, Limit: 2}
+	pages, messages := 0, 0
+	for {
+		page, err := db.SearchMessages(opts)
+		if err != nil {
+			t.Fatalf("failed to search messages: %v", err)
+		}
+		pages++
+		if page.TotalMatches != 5 {
+			t.Errorf("expected 5 total messages, got %d", page.TotalMatches)
+		}
+		messages += len(page.Messages)
+		if !page.HasMore {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	if pages != 3 || messages != 5 {
+		t.Errorf("expected 5 messages over 3 pages, got %d over %d", messages, pages)
+	}
+}
+
+func TestCursorTime(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	encoded := cursor{Now: now, Offset: 10, Fingerprint: "abc"}.encode()
+
+	got, err := CursorTime(encoded)
+	if err != nil {
+		t.Fatalf("CursorTime error: %v", err)
+	}
+	if !got.Equal(now) {
+		t.Errorf("CursorTime = %v, want %v", got, now)
+	}
+}
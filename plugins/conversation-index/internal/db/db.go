@@ -3,6 +3,8 @@ package db
 import (
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/shared"
 	_ "modernc.org/sqlite"
@@ -11,15 +13,35 @@ import (
 // DB interface defines all database operations
 type DB interface {
 	InitSchema() error
+	SchemaVersion() (int, error)
+	PendingMigrations() ([]Migration, error)
+	Migrate() ([]Migration, error)
+	Tokenizer() (string, error)
+	SetTokenizer(t Tokenizer) (bool, error)
 	TruncateAll() error
 	SaveConversation(conv *Conversation) error
 	SaveMessages(messages []Message) error
+	SaveToolCalls(calls []ToolCall) error
+	SaveConversations(updates []ConversationUpdate) error
+	SaveEntries(entries []Entry) error
+	UpdateActiveBranch(uuid string) error
+	PruneConversation(uuid string, keepEntries []string) error
+	LinkSidechains() error
 	GetIndexState(uuid string) (*IndexState, error)
 	UpdateIndexState(state *IndexState) error
+	SetTitle(title Message) error
 	DeleteConversation(uuid string) error
 	DeleteIndexState(uuid string) error
+	ListConversations() ([]Conversation, error)
+	RemoveConversation(uuid string) error
+	LastPruned() (time.Time, error)
+	SetLastPruned(t time.Time) error
 	GetFirstUserMessage(uuid string) (string, error)
-	Search(query, scope, projectPath string, limit int) ([]Match, error)
+	Search(opts SearchOptions) (*SearchPage, error)
+	SearchMessages(opts SearchOptions) (*MessagePage, error)
+	FilesTouched(opts FileOptions) ([]FileActivity, error)
+	Maintain() (*MaintenanceReport, error)
+	RewriteContent(rewrite func(string) string) (int, error)
 	Close() error
 }
 
@@ -31,8 +53,9 @@ type sqliteDB struct {
 // Open opens a SQLite database at the given path
 func Open(path string) (DB, error) {
 	// Add mode=rwc to ensure read-write-create access
-	// Add busy_timeout to handle concurrent access (wait up to 5 seconds)
-	connStr := path + "?mode=rwc&_busy_timeout=5000"
+	// Add busy_timeout to handle concurrent access (wait up to 5 seconds).
+	// The driver only applies pragmas given as _pragma, on every connection.
+	connStr := path + "?mode=rwc&_pragma=busy_timeout(5000)"
 	conn, err := sql.Open("sqlite", connStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
@@ -49,6 +72,9 @@ func (db *sqliteDB) TruncateAll() error {
 	// Delete in order to respect foreign key constraints
 	queries := []string{
 		"DELETE FROM messages",
+		"DELETE FROM tool_calls",
+		"DELETE FROM entries",
+		"DELETE FROM summaries",
 		"DELETE FROM conversations",
 		"DELETE FROM index_state",
 	}
@@ -62,82 +88,59 @@ func (db *sqliteDB) TruncateAll() error {
 	return nil
 }
 
-// InitSchema creates all tables, indexes, and triggers
+// InitSchema brings the schema up to date by applying any pending migrations
 func (db *sqliteDB) InitSchema() error {
-	schema := `
-		CREATE TABLE IF NOT EXISTS conversations (
-			uuid TEXT PRIMARY KEY,
-			project_path TEXT NOT NULL,
-			encoded_path TEXT NOT NULL,
-			created_at TEXT NOT NULL,
-			last_updated TEXT NOT NULL,
-			message_count INTEGER DEFAULT 0
-		);
-
-		CREATE TABLE IF NOT EXISTS messages (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			conversation_uuid TEXT NOT NULL,
-			timestamp TEXT NOT NULL,
-			role TEXT NOT NULL,
-			content TEXT,
-			FOREIGN KEY (conversation_uuid) REFERENCES conversations(uuid)
-		);
-
-		CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
-			conversation_uuid,
-			content,
-			content=messages,
-			content_rowid=id
-		);
-
-		CREATE TABLE IF NOT EXISTS index_state (
-			conversation_uuid TEXT PRIMARY KEY,
-			last_indexed_line INTEGER DEFAULT 0,
-			last_modified_time TEXT
-		);
-
-		CREATE TRIGGER IF NOT EXISTS messages_ai AFTER INSERT ON messages BEGIN
-			INSERT INTO messages_fts(rowid, conversation_uuid, content)
-			VALUES (new.id, new.conversation_uuid, new.content);
-		END;
-
-		CREATE TRIGGER IF NOT EXISTS messages_ad AFTER DELETE ON messages BEGIN
-			DELETE FROM messages_fts WHERE rowid = old.id;
-		END;
-
-		CREATE TRIGGER IF NOT EXISTS messages_au AFTER UPDATE ON messages BEGIN
-			DELETE FROM messages_fts WHERE rowid = old.id;
-			INSERT INTO messages_fts(rowid, conversation_uuid, content)
-			VALUES (new.id, new.conversation_uuid, new.content);
-		END;
-	`
-
-	_, err := db.conn.Exec(schema)
-	if err != nil {
+	if _, err := db.Migrate(); err != nil {
 		return fmt.Errorf("failed to initialize schema: %w", err)
 	}
 
 	return nil
 }
 
-// SaveConversation inserts or updates a conversation record
+// SaveConversation inserts or updates a conversation record. Empty
+// session metadata fields keep their stored values, so an incremental run
+// only needs the metadata seen in new lines.
 func (db *sqliteDB) SaveConversation(conv *Conversation) error {
+	return saveConversation(db.conn, conv)
+}
+
+func saveConversation(q execer, conv *Conversation) error {
 	query := `
-		INSERT INTO conversations (uuid, project_path, encoded_path, created_at, last_updated, message_count)
-		VALUES (?, ?, ?, ?, ?, COALESCE((SELECT message_count FROM conversations WHERE uuid = ?), 0))
+		INSERT INTO conversations (
+			uuid, project_path, encoded_path, created_at, last_updated, message_count, transcript_path,
+			session_id, git_branch, claude_version, model, permission_mode, parent_uuid
+		)
+		VALUES (
+			?, ?, ?, ?, ?, COALESCE((SELECT message_count FROM conversations WHERE uuid = ?), 0), NULLIF(?, ''),
+			NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, '')
+		)
 		ON CONFLICT(uuid) DO UPDATE SET
-			project_path = excluded.project_path,
+			project_path = COALESCE(NULLIF(excluded.project_path, ''), project_path),
 			encoded_path = excluded.encoded_path,
-			last_updated = excluded.last_updated
+			last_updated = excluded.last_updated,
+			transcript_path = COALESCE(excluded.transcript_path, transcript_path),
+			session_id = COALESCE(excluded.session_id, session_id),
+			git_branch = COALESCE(excluded.git_branch, git_branch),
+			claude_version = COALESCE(excluded.claude_version, claude_version),
+			model = COALESCE(excluded.model, model),
+			permission_mode = COALESCE(excluded.permission_mode, permission_mode),
+			parent_uuid = COALESCE(excluded.parent_uuid, parent_uuid)
 	`
 
-	_, err := db.conn.Exec(query,
+	_, err := q.Exec(query,
 		conv.UUID,
 		conv.ProjectPath,
 		conv.EncodedPath,
 		shared.FormatTimestamp(conv.CreatedAt),
 		shared.FormatTimestamp(conv.LastUpdated),
 		conv.UUID,
+		conv.TranscriptPath,
+		conv.SessionID,
+		conv.GitBranch,
+		conv.ClaudeVersion,
+		conv.Model,
+		conv.PermissionMode,
+		conv.ParentUUID,
 	)
 
 	if err != nil {
@@ -147,72 +150,130 @@ func (db *sqliteDB) SaveConversation(conv *Conversation) error {
 	return nil
 }
 
-// SaveMessages inserts multiple messages in a transaction
+// SaveMessages inserts multiple messages in a transaction. Messages of a
+// transcript entry are upserted on the entry uuid and part, so indexing the
+// same entry again updates it in place.
 func (db *sqliteDB) SaveMessages(messages []Message) error {
 	if len(messages) == 0 {
 		return nil
 	}
+	return db.inTx(func(tx *sql.Tx) error { return saveMessages(tx, messages) })
+}
+
+// messageInsertRows is the number of messages inserted per statement, well
+// under SQLite's limit of 32766 parameters
+const messageInsertRows = 100
+
+func saveMessages(q execer, messages []Message) error {
+	if len(messages) == 0 {
+		return nil
+	}
 
-	tx, err := db.conn.Begin()
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+	// Insert several rows per statement: FTS5 flushes its pending terms at
+	// every statement, which dominates the cost of one row per statement
+	for start := 0; start < len(messages); start += messageInsertRows {
+		chunk := messages[start:min(start+messageInsertRows, len(messages))]
+		rows := make([]string, len(chunk))
+		args := make([]any, 0, len(chunk)*10)
+		for i, msg := range chunk {
+			rows[i] = "(?, ?, ?, ?, NULLIF(?, 0), NULLIF(?, ''), ?, NULLIF(?, ''), ?, ?)"
+			args = append(args,
+				msg.ConversationUUID,
+				shared.FormatTimestamp(msg.Timestamp),
+				msg.Role,
+				stripMarkers(msg.Content),
+				msg.Line,
+				msg.Model,
+				msg.IsError,
+				msg.EntryUUID,
+				msg.Part,
+				msg.Sidechain,
+			)
+		}
+
+		_, err := q.Exec(`
+			INSERT INTO messages (conversation_uuid, timestamp, role, content, line, model, is_error, entry_uuid, part, sidechain)
+			VALUES `+strings.Join(rows, ", ")+`
+			ON CONFLICT(conversation_uuid, entry_uuid, part) WHERE entry_uuid IS NOT NULL DO UPDATE SET
+				timestamp = excluded.timestamp,
+				role = excluded.role,
+				content = excluded.content,
+				line = excluded.line,
+				model = excluded.model,
+				is_error = excluded.is_error,
+				sidechain = excluded.sidechain
+		`, args...)
+		if err != nil {
+			return fmt.Errorf("failed to insert messages: %w", err)
+		}
+	}
+
+	// Recount rather than add, since upserted messages were already counted
+	return updateMessageCount(q, messages[0].ConversationUUID)
+}
+
+// SaveToolCalls inserts multiple tool calls in a transaction
+func (db *sqliteDB) SaveToolCalls(calls []ToolCall) error {
+	if len(calls) == 0 {
+		return nil
+	}
+	return db.inTx(func(tx *sql.Tx) error { return saveToolCalls(tx, calls) })
+}
+
+func saveToolCalls(q execer, calls []ToolCall) error {
+	if len(calls) == 0 {
+		return nil
 	}
-	defer tx.Rollback()
 
-	stmt, err := tx.Prepare(`
-		INSERT INTO messages (conversation_uuid, timestamp, role, content)
-		VALUES (?, ?, ?, ?)
+	stmt, err := q.Prepare(`
+		INSERT INTO tool_calls (conversation_uuid, timestamp, tool, file_path, command, pattern, line, subagent_type, prompt)
+		VALUES (?, ?, ?, NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, ''), NULLIF(?, 0), NULLIF(?, ''), NULLIF(?, ''))
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
 	}
 	defer stmt.Close()
 
-	for _, msg := range messages {
+	for _, call := range calls {
 		_, err := stmt.Exec(
-			msg.ConversationUUID,
-			shared.FormatTimestamp(msg.Timestamp),
-			msg.Role,
-			msg.Content,
+			call.ConversationUUID,
+			shared.FormatTimestamp(call.Timestamp),
+			call.Tool,
+			call.FilePath,
+			call.Command,
+			call.Pattern,
+			call.Line,
+			call.SubagentType,
+			call.Prompt,
 		)
 		if err != nil {
-			return fmt.Errorf("failed to insert message: %w", err)
+			return fmt.Errorf("failed to insert tool call: %w", err)
 		}
 	}
 
-	// Update message count
-	updateQuery := `
-		UPDATE conversations
-		SET message_count = message_count + ?
-		WHERE uuid = ?
-	`
-	_, err = tx.Exec(updateQuery, len(messages), messages[0].ConversationUUID)
-	if err != nil {
-		return fmt.Errorf("failed to update message count: %w", err)
-	}
-
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit transaction: %w", err)
-	}
-
 	return nil
 }
 
 // GetIndexState retrieves the index state for a conversation
 func (db *sqliteDB) GetIndexState(uuid string) (*IndexState, error) {
 	query := `
-		SELECT conversation_uuid, last_indexed_line, last_modified_time
+		SELECT conversation_uuid, last_indexed_line, last_modified_time, byte_offset, file_size, inode, prefix_checksum
 		FROM index_state
 		WHERE conversation_uuid = ?
 	`
 
 	var state IndexState
 	var modifiedStr string
+	var inode int64
 
 	err := db.conn.QueryRow(query, uuid).Scan(
 		&state.ConversationUUID,
 		&state.LastIndexedLine,
 		&modifiedStr,
+		&state.ByteOffset,
+		&state.FileSize,
+		&inode,
+		&state.PrefixChecksum,
 	)
 
 	if err == sql.ErrNoRows {
@@ -228,21 +289,30 @@ func (db *sqliteDB) GetIndexState(uuid string) (*IndexState, error) {
 		return nil, fmt.Errorf("failed to parse timestamp: %w", err)
 	}
 	state.LastModifiedTime = modifiedTime
+	state.Inode = uint64(inode) // Stored as SQLite's signed integer
 
 	return &state, nil
 }
 
 // UpdateIndexState inserts or updates the index state for a conversation
 func (db *sqliteDB) UpdateIndexState(state *IndexState) error {
+	return updateIndexState(db.conn, state)
+}
+
+func updateIndexState(q execer, state *IndexState) error {
 	query := `
-		INSERT OR REPLACE INTO index_state (conversation_uuid, last_indexed_line, last_modified_time)
-		VALUES (?, ?, ?)
+		INSERT OR REPLACE INTO index_state (conversation_uuid, last_indexed_line, last_modified_time, byte_offset, file_size, inode, prefix_checksum)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := db.conn.Exec(query,
+	_, err := q.Exec(query,
 		state.ConversationUUID,
 		state.LastIndexedLine,
 		shared.FormatTimestamp(state.LastModifiedTime),
+		state.ByteOffset,
+		state.FileSize,
+		int64(state.Inode),
+		state.PrefixChecksum,
 	)
 
 	if err != nil {
@@ -252,7 +322,7 @@ func (db *sqliteDB) UpdateIndexState(state *IndexState) error {
 	return nil
 }
 
-// DeleteConversation deletes all messages for a conversation
+// DeleteConversation deletes all messages, tool calls, entries and summaries for a conversation
 func (db *sqliteDB) DeleteConversation(uuid string) error {
 	query := `DELETE FROM messages WHERE conversation_uuid = ?`
 
@@ -261,8 +331,23 @@ func (db *sqliteDB) DeleteConversation(uuid string) error {
 		return fmt.Errorf("failed to delete conversation messages: %w", err)
 	}
 
-	// Reset message count
-	updateQuery := `UPDATE conversations SET message_count = 0 WHERE uuid = ?`
+	_, err = db.conn.Exec(`DELETE FROM tool_calls WHERE conversation_uuid = ?`, uuid)
+	if err != nil {
+		return fmt.Errorf("failed to delete conversation tool calls: %w", err)
+	}
+
+	_, err = db.conn.Exec(`DELETE FROM entries WHERE conversation_uuid = ?`, uuid)
+	if err != nil {
+		return fmt.Errorf("failed to delete conversation entries: %w", err)
+	}
+
+	_, err = db.conn.Exec(`DELETE FROM summaries WHERE conversation_uuid = ?`, uuid)
+	if err != nil {
+		return fmt.Errorf("failed to delete conversation summaries: %w", err)
+	}
+
+	// Reset message count and title
+	updateQuery := `UPDATE conversations SET message_count = 0, title = NULL WHERE uuid = ?`
 	_, err = db.conn.Exec(updateQuery, uuid)
 	if err != nil {
 		return fmt.Errorf("failed to reset message count: %w", err)
@@ -271,6 +356,35 @@ func (db *sqliteDB) DeleteConversation(uuid string) error {
 	return nil
 }
 
+// SetTitle records a summary entry as its conversation's title, replacing
+// any earlier title. The title is also indexed as a message with role
+// "title" so searches match it, but it doesn't count towards message_count.
+func (db *sqliteDB) SetTitle(title Message) error {
+	return db.inTx(func(tx *sql.Tx) error { return setTitle(tx, title) })
+}
+
+func setTitle(q execer, title Message) error {
+	_, err := q.Exec(`UPDATE conversations SET title = ? WHERE uuid = ?`, title.Content, title.ConversationUUID)
+	if err != nil {
+		return fmt.Errorf("failed to update title: %w", err)
+	}
+
+	_, err = q.Exec(`DELETE FROM messages WHERE conversation_uuid = ? AND role = 'title'`, title.ConversationUUID)
+	if err != nil {
+		return fmt.Errorf("failed to delete old title: %w", err)
+	}
+
+	_, err = q.Exec(`
+		INSERT INTO messages (conversation_uuid, timestamp, role, content, line)
+		VALUES (?, ?, 'title', ?, NULLIF(?, 0))
+	`, title.ConversationUUID, shared.FormatTimestamp(title.Timestamp), stripMarkers(title.Content), title.Line)
+	if err != nil {
+		return fmt.Errorf("failed to insert title: %w", err)
+	}
+
+	return nil
+}
+
 // DeleteIndexState deletes the index state for a conversation
 func (db *sqliteDB) DeleteIndexState(uuid string) error {
 	query := `DELETE FROM index_state WHERE conversation_uuid = ?`
@@ -306,37 +420,119 @@ func (db *sqliteDB) GetFirstUserMessage(uuid string) (string, error) {
 	return content, nil
 }
 
-// Search performs an FTS5 search across conversations
-func (db *sqliteDB) Search(query, scope, projectPath string, limit int) ([]Match, error) {
-	sqlQuery := `
-		SELECT
-			c.uuid,
-			c.project_path,
-			c.encoded_path,
-			c.created_at,
-			c.last_updated,
-			c.message_count,
-			messages_fts.rank as relevance_score
-		FROM messages_fts
-		JOIN messages m ON messages_fts.rowid = m.id
-		JOIN conversations c ON m.conversation_uuid = c.uuid
-		WHERE messages_fts MATCH ?
-	`
+// Search performs an FTS5 search across conversations, ranked by the
+// aggregated score of each conversation's matching messages, and returns
+// the page of results selected by opts.Offset or opts.Cursor
+func (db *sqliteDB) Search(opts SearchOptions) (*SearchPage, error) {
+	opts, page, err := startPage(opts, db.latestMessageID)
+	if err != nil {
+		return nil, err
+	}
+	input := opts.Query
+	opts, err = compileSearch(opts)
+	if err != nil {
+		return nil, err
+	}
 
-	args := []interface{}{query}
+	idx := searchIndex(opts)
+	filters, filterArgs := searchFilters(opts)
+	weights := rankingWeights(opts)
+	score, scoreArgs := messageScore(idx, weights)
 
-	// Add project scope filtering
-	if scope == "current_project" && projectPath != "" {
-		sqlQuery += ` AND c.encoded_path = ?`
-		args = append(args, projectPath)
+	relevance := "total"
+	decay, decayArgs := recencyDecay(weights, opts.Now, "last_active")
+	if decay != "" {
+		relevance += " * " + decay
+	}
+
+	// Hits in a subagent's sidechain count towards the conversation that
+	// started it, when that conversation is indexed
+	hits := `
+		WITH hits AS (
+			SELECT r.uuid AS uuid, m.sidechain AS sidechain, ` + score + ` AS score
+			FROM ` + idx.table + `
+			JOIN messages m ON ` + idx.table + `.rowid = m.id
+			JOIN conversations c ON m.conversation_uuid = c.uuid` + rollupJoin + `
+			WHERE ` + idx.table + ` MATCH ?
+	` + filters + `
+		)`
+	hitArgs := append([]interface{}{}, scoreArgs...)
+	hitArgs = append(hitArgs, opts.Query)
+	hitArgs = append(hitArgs, filterArgs...)
+
+	having := ""
+	if opts.HideSidechainOnly {
+		having = ` HAVING SUM(sidechain) < COUNT(*)`
+	}
+
+	// Later pages start after the previous page's last result
+	after, offset := "", page.Offset
+	var afterArgs []interface{}
+	if page.After != nil {
+		after = "WHERE relevance_score < ? OR (relevance_score = ? AND uuid > ?)"
+		afterArgs = []interface{}{page.After.Score, page.After.Score, page.After.UUID}
+		offset = 0
+	}
+	lastActive, lastActiveArgs := lastActivity("c", opts.snapshot)
+
+	var total int
+	err = db.conn.QueryRow(hits+` SELECT COUNT(*) FROM (SELECT uuid FROM hits GROUP BY uuid`+having+`)`, hitArgs...).Scan(&total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count search results: %w", err)
+	}
+	if total == 0 {
+		suggestions, err := db.suggestions(input, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &SearchPage{PageInfo: pageInfo(page, 0, 0, false, nil), Matches: []Match{}, Suggestions: suggestions}, nil
 	}
 
-	sqlQuery += `
-		GROUP BY c.uuid
-		ORDER BY relevance_score DESC
-		LIMIT ?
+	sqlQuery := hits + `,
+		ranked AS (
+			SELECT uuid, sidechain, score, ROW_NUMBER() OVER (PARTITION BY uuid ORDER BY score DESC) AS n
+			FROM hits
+		),
+		scored AS (
+			SELECT uuid, SUM(score / n) AS total, COUNT(*) AS hit_count, SUM(sidechain) AS sidechain_hits
+			FROM ranked
+			GROUP BY uuid` + having + `
+		),
+		results AS (
+			SELECT
+				c.uuid,
+				c.project_path,
+				c.encoded_path,
+				c.created_at,
+				c.last_updated,
+				c.message_count,
+				COALESCE(c.title, '') AS title,
+				COALESCE(c.git_branch, '') AS git_branch,
+				COALESCE(c.model, '') AS model,
+				s.hit_count,
+				s.sidechain_hits,
+				s.total,
+				` + lastActive + ` AS last_active
+			FROM scored s
+			JOIN conversations c ON c.uuid = s.uuid
+		),
+		ordered AS (
+			SELECT *, ` + relevance + ` AS relevance_score FROM results
+		)
+		SELECT
+			uuid, project_path, encoded_path, created_at, last_updated, message_count,
+			title, git_branch, model, hit_count, sidechain_hits, relevance_score
+		FROM ordered
+		` + after + `
+		ORDER BY relevance_score DESC, uuid
+		LIMIT ? OFFSET ?
 	`
-	args = append(args, limit)
+
+	args := append([]interface{}{}, hitArgs...)
+	args = append(args, lastActiveArgs...)
+	args = append(args, decayArgs...)
+	args = append(args, afterArgs...)
+	args = append(args, keysetLimit(opts.Limit), offset)
 
 	rows, err := db.conn.Query(sqlQuery, args...)
 	if err != nil {
@@ -344,7 +540,7 @@ func (db *sqliteDB) Search(query, scope, projectPath string, limit int) ([]Match
 	}
 	defer rows.Close()
 
-	var matches []Match
+	matches := []Match{}
 	for rows.Next() {
 		var match Match
 		err := rows.Scan(
@@ -354,39 +550,175 @@ func (db *sqliteDB) Search(query, scope, projectPath string, limit int) ([]Match
 			&match.CreatedAt,
 			&match.LastUpdated,
 			&match.MessageCount,
+			&match.Title,
+			&match.GitBranch,
+			&match.Model,
+			&match.Hits,
+			&match.SidechainHits,
 			&match.RelevanceScore,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
 		}
 
-		// Get summary (first user message)
-		summary, err := db.GetFirstUserMessage(match.UUID)
-		if err != nil {
-			return nil, fmt.Errorf("failed to get summary: %w", err)
+		matches = append(matches, match)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	more := opts.Limit > 0 && len(matches) > opts.Limit
+	if more {
+		matches = matches[:opts.Limit]
+	}
+	if err := db.addDetails(opts, matches); err != nil {
+		return nil, err
+	}
+	var last *pageKey
+	if n := len(matches); n > 0 {
+		last = &pageKey{Score: matches[n-1].RelevanceScore, UUID: matches[n-1].UUID}
+	}
+
+	return &SearchPage{
+		PageInfo: pageInfo(page, total, len(matches), more, last),
+		Matches:  matches,
+	}, nil
+}
+
+// lastActivity returns the SQL expression for when the conversation aliased
+// as conv was last active, as a Julian day: its latest message up to
+// snapshot, so writes after a paged search began don't change its recency
+func lastActivity(conv string, snapshot int64) (string, []interface{}) {
+	expr := `COALESCE(
+					(SELECT MAX(julianday(timestamp)) FROM messages WHERE conversation_uuid = ` + conv + `.uuid AND id <= ?),
+					julianday(` + conv + `.last_updated))`
+	return expr, []interface{}{snapshot}
+}
+
+// latestMessageID returns the highest message id, the snapshot a new page
+// of search results is pinned to
+func (db *sqliteDB) latestMessageID() (int64, error) {
+	var id int64
+	if err := db.conn.QueryRow(`SELECT COALESCE(MAX(id), 0) FROM messages`).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to read latest message id: %w", err)
+	}
+	return id, nil
+}
+
+// rollupJoin joins r, the conversation a message's hits count towards: the
+// one that started its sidechain when that is indexed, otherwise its own.
+// Conversation filters apply to r, so a subagent can't bring back a
+// conversation they exclude.
+const rollupJoin = `
+			LEFT JOIN conversations p ON p.uuid = c.parent_uuid
+			JOIN conversations r ON r.uuid = COALESCE(p.uuid, c.uuid)`
+
+// searchFilters builds the SQL predicates for the non-FTS search options.
+// Predicates refer to messages as m and conversations as c, and each one
+// starts with AND so the result can be appended to a WHERE clause.
+func searchFilters(opts SearchOptions) (string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+
+	// Add project scope filtering
+	if opts.Scope == ScopeCurrentProject && opts.ProjectPath != "" {
+		clauses = append(clauses, "r.encoded_path = ?")
+		args = append(args, opts.ProjectPath)
+	}
+
+	// Compare with julianday() so sub-second precision and zone offsets
+	// in stored timestamps don't break lexical ordering
+	if !opts.Since.IsZero() {
+		clauses = append(clauses, "julianday(m.timestamp) >= julianday(?)")
+		args = append(args, shared.FormatTimestamp(opts.Since.UTC()))
+	}
+	if !opts.Until.IsZero() {
+		clauses = append(clauses, "julianday(m.timestamp) < julianday(?)")
+		args = append(args, shared.FormatTimestamp(opts.Until.UTC()))
+	}
+
+	if len(opts.Roles) > 0 {
+		clauses = append(clauses, "m.role IN ("+placeholders(len(opts.Roles))+")")
+		for _, role := range opts.Roles {
+			args = append(args, role)
 		}
+	}
+
+	if opts.ErrorsOnly {
+		clauses = append(clauses, "m.is_error = 1")
+	}
+
+	if !opts.IncludeAbandoned {
+		clauses = append(clauses, "m.abandoned = 0")
+	}
+
+	if opts.MinMessages > 0 {
+		clauses = append(clauses, "r.message_count >= ?")
+		args = append(args, opts.MinMessages)
+	}
+	if opts.MaxMessages > 0 {
+		clauses = append(clauses, "r.message_count <= ?")
+		args = append(args, opts.MaxMessages)
+	}
 
-		// Truncate summary to 150 characters (UTF-8 safe)
-		if summary == "" {
-			summary = "No summary available"
-		} else {
-			summary = shared.TruncateString(summary, 150)
+	if len(opts.Branches) > 0 {
+		clauses = append(clauses, "r.git_branch IN ("+placeholders(len(opts.Branches))+")")
+		for _, branch := range opts.Branches {
+			args = append(args, branch)
 		}
-		match.Summary = summary
+	}
 
-		// Convert relevance score to absolute value
-		if match.RelevanceScore < 0 {
-			match.RelevanceScore = -match.RelevanceScore
+	// A conversation matches a model if any of its messages came from it
+	if len(opts.Models) > 0 {
+		clauses = append(clauses, modelFilter(len(opts.Models)))
+		for _, model := range opts.Models {
+			args = append(args, "%"+escapeLike(model)+"%")
 		}
+	}
 
-		matches = append(matches, match)
+	if len(opts.ExcludeUUIDs) > 0 {
+		clauses = append(clauses, "r.uuid NOT IN ("+placeholders(len(opts.ExcludeUUIDs))+")")
+		for _, uuid := range opts.ExcludeUUIDs {
+			args = append(args, uuid)
+		}
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
+	// Pin paged searches to the messages indexed when the first page ran
+	if opts.snapshot > 0 {
+		clauses = append(clauses, "m.id <= ?")
+		args = append(args, opts.snapshot)
+	}
+
+	// Field filters from the query itself
+	if opts.parsed != nil {
+		queryClauses, queryArgs := opts.parsed.predicates()
+		clauses = append(clauses, queryClauses...)
+		args = append(args, queryArgs...)
 	}
 
-	return matches, nil
+	if len(clauses) == 0 {
+		return "", nil
+	}
+	return " AND " + strings.Join(clauses, " AND "), args
+}
+
+// modelFilter returns a predicate matching conversations with a message from
+// any of n models, each passed as a LIKE pattern
+func modelFilter(n int) string {
+	ors := make([]string, n)
+	for i := range ors {
+		ors[i] = `mm.model LIKE ? ESCAPE '\'`
+	}
+	return `EXISTS (
+				SELECT 1 FROM messages mm
+				WHERE mm.conversation_uuid = r.uuid AND (` + strings.Join(ors, " OR ") + `)
+			)`
+}
+
+// placeholders returns n comma-separated SQL parameter placeholders
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
 }
 
 // Close closes the database connection
@@ -3,6 +3,7 @@ package db
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -69,9 +70,13 @@ func TestSQLiteDB_Integration(t *testing.T) {
 
 	// Test index state operations
 	state := &IndexState{
-		ConversationUUID:  "test-uuid-1",
-		LastIndexedLine:   10,
-		LastModifiedTime:  time.Now(),
+		ConversationUUID: "test-uuid-1",
+		LastIndexedLine:  10,
+		LastModifiedTime: time.Now(),
+		ByteOffset:       4096,
+		FileSize:         4200,
+		Inode:            1<<63 + 7,
+		PrefixChecksum:   0xdeadbeef,
 	}
 
 	if err := db.UpdateIndexState(state); err != nil {
@@ -91,11 +96,16 @@ func TestSQLiteDB_Integration(t *testing.T) {
 		t.Errorf("expected last indexed line to be 10, got %d", retrievedState.LastIndexedLine)
 	}
 
+	if retrievedState.ByteOffset != 4096 || retrievedState.FileSize != 4200 || retrievedState.Inode != 1<<63+7 || retrievedState.PrefixChecksum != 0xdeadbeef {
+		t.Errorf("expected offset, size, inode and checksum to round trip, got %+v", retrievedState)
+	}
+
 	// Test search (FTS5)
-	matches, err := db.Search("test message", "all_projects", "", 10)
+	page, err := db.Search(SearchOptions{Query: "test message", Scope: ScopeAllProjects, Limit: 10})
 	if err != nil {
 		t.Fatalf("failed to search: %v", err)
 	}
+	matches := page.Matches
 
 	if len(matches) != 1 {
 		t.Errorf("expected 1 match, got %d", len(matches))
@@ -109,6 +119,27 @@ func TestSQLiteDB_Integration(t *testing.T) {
 		if matches[0].Summary != "Hello, this is a test message" {
 			t.Errorf("expected summary 'Hello, this is a test message', got %q", matches[0].Summary)
 		}
+
+		if len(matches[0].Excerpts) == 0 {
+			t.Fatal("expected excerpts, got none")
+		}
+
+		excerpt := matches[0].Excerpts[0]
+		if excerpt.Role != "user" {
+			t.Errorf("expected excerpt role 'user', got %q", excerpt.Role)
+		}
+
+		if excerpt.Text != "Hello, this is a test message" {
+			t.Errorf("expected excerpt text 'Hello, this is a test message', got %q", excerpt.Text)
+		}
+
+		var highlighted []string
+		for _, h := range excerpt.Highlights {
+			highlighted = append(highlighted, excerpt.Text[h.Start:h.End])
+		}
+		if len(highlighted) != 2 || highlighted[0] != "test" || highlighted[1] != "message" {
+			t.Errorf("expected highlights [test message], got %v", highlighted)
+		}
 	}
 
 	// Test delete conversation
@@ -143,19 +174,19 @@ func TestSQLiteDB_SearchScoping(t *testing.T) {
 
 	// Create two conversations in different projects
 	conv1 := &Conversation{
-		UUID:         "project1-conv",
-		ProjectPath:  "/Users/test/project1",
-		EncodedPath:  "-Users-test-project1",
-		CreatedAt:    time.Now(),
-		LastUpdated:  time.Now(),
+		UUID:        "project1-conv",
+		ProjectPath: "/Users/test/project1",
+		EncodedPath: "-Users-test-project1",
+		CreatedAt:   time.Now(),
+		LastUpdated: time.Now(),
 	}
 
 	conv2 := &Conversation{
-		UUID:         "project2-conv",
-		ProjectPath:  "/Users/test/project2",
-		EncodedPath:  "-Users-test-project2",
-		CreatedAt:    time.Now(),
-		LastUpdated:  time.Now(),
+		UUID:        "project2-conv",
+		ProjectPath: "/Users/test/project2",
+		EncodedPath: "-Users-test-project2",
+		CreatedAt:   time.Now(),
+		LastUpdated: time.Now(),
 	}
 
 	if err := db.SaveConversation(conv1); err != nil {
@@ -190,20 +221,27 @@ func TestSQLiteDB_SearchScoping(t *testing.T) {
 	}
 
 	// Search all projects
-	allMatches, err := db.Search("database query", "all_projects", "", 10)
+	allPage, err := db.Search(SearchOptions{Query: "database query", Scope: ScopeAllProjects, Limit: 10})
 	if err != nil {
 		t.Fatalf("failed to search all projects: %v", err)
 	}
+	allMatches := allPage.Matches
 
 	if len(allMatches) != 2 {
 		t.Errorf("expected 2 matches in all projects, got %d", len(allMatches))
 	}
 
 	// Search current project only
-	currentMatches, err := db.Search("database query", "current_project", "-Users-test-project1", 10)
+	currentPage, err := db.Search(SearchOptions{
+		Query:       "database query",
+		Scope:       ScopeCurrentProject,
+		ProjectPath: "-Users-test-project1",
+		Limit:       10,
+	})
 	if err != nil {
 		t.Fatalf("failed to search current project: %v", err)
 	}
+	currentMatches := currentPage.Matches
 
 	if len(currentMatches) != 1 {
 		t.Errorf("expected 1 match in current project, got %d", len(currentMatches))
@@ -214,6 +252,310 @@ func TestSQLiteDB_SearchScoping(t *testing.T) {
 	}
 }
 
+func TestSQLiteDB_SearchHighlightMarkersInContent(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.InitSchema(); err != nil {
+		t.Fatalf("failed to initialize schema: %v", err)
+	}
+
+	conv := &Conversation{
+		UUID:        "conv",
+		ProjectPath: "/Users/test/project",
+		EncodedPath: "-Users-test-project",
+		CreatedAt:   time.Now(),
+		LastUpdated: time.Now(),
+	}
+	if err := db.SaveConversation(conv); err != nil {
+		t.Fatalf("failed to save conversation: %v", err)
+	}
+	message := Message{ConversationUUID: "conv", Timestamp: time.Now(), Role: "tool", Content: "raw \x02terminal\x03 output from zeebe"}
+	if err := db.SaveMessages([]Message{message}); err != nil {
+		t.Fatalf("failed to save messages: %v", err)
+	}
+
+	page, err := db.Search(SearchOptions{Query: "zeebe", Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(page.Matches) != 1 || len(page.Matches[0].Excerpts) != 1 {
+		t.Fatalf("expected one excerpt, got %+v", page.Matches)
+	}
+	excerpt := page.Matches[0].Excerpts[0]
+	if excerpt.Text != "raw terminal output from zeebe" {
+		t.Errorf("expected the markers to be stripped, got %q", excerpt.Text)
+	}
+	if want := []Highlight{{Start: 25, End: 30}}; !reflect.DeepEqual(excerpt.Highlights, want) {
+		t.Errorf("expected only the match to be highlighted, got %+v", excerpt.Highlights)
+	}
+}
+
+func TestSQLiteDB_SearchFilters(t *testing.T) {
+	tmpDir := t.TempDir()
+	dbPath := filepath.Join(tmpDir, "test.db")
+
+	db, err := Open(dbPath)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.InitSchema(); err != nil {
+		t.Fatalf("failed to initialize schema: %v", err)
+	}
+
+	day := func(d int) time.Time {
+		return time.Date(2026, 1, d, 12, 0, 0, 0, time.UTC)
+	}
+
+	// "early" has a single user message; "late" has a longer exchange
+	conversations := map[string][]Message{
+		"early": {
+			{Timestamp: day(1), Role: "user", Content: "deploy the zeebe worker"},
+		},
+		"late": {
+			{Timestamp: day(10), Role: "user", Content: "why did the zeebe worker fail"},
+			{Timestamp: day(10), Role: "assistant", Content: "the zeebe worker timed out"},
+			{Timestamp: day(10), Role: "tool", Content: "Tool: Bash Command: kubectl logs zeebe worker"},
+		},
+	}
+
+	for uuid, messages := range conversations {
+		conv := &Conversation{
+			UUID:        uuid,
+			ProjectPath: "/Users/test/project",
+			EncodedPath: "-Users-test-project",
+			CreatedAt:   messages[0].Timestamp,
+			LastUpdated: messages[0].Timestamp,
+		}
+		if err := db.SaveConversation(conv); err != nil {
+			t.Fatalf("failed to save conversation %s: %v", uuid, err)
+		}
+		for i := range messages {
+			messages[i].ConversationUUID = uuid
+		}
+		if err := db.SaveMessages(messages); err != nil {
+			t.Fatalf("failed to save messages for %s: %v", uuid, err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts SearchOptions
+		want []string
+	}{
+		{
+			name: "no filters",
+			opts: SearchOptions{},
+			want: []string{"early", "late"},
+		},
+		{
+			name: "since",
+			opts: SearchOptions{Since: day(5)},
+			want: []string{"late"},
+		},
+		{
+			name: "until",
+			opts: SearchOptions{Until: day(5)},
+			want: []string{"early"},
+		},
+		{
+			name: "role",
+			opts: SearchOptions{Roles: []string{"assistant", "tool"}},
+			want: []string{"late"},
+		},
+		{
+			name: "min messages",
+			opts: SearchOptions{MinMessages: 2},
+			want: []string{"late"},
+		},
+		{
+			name: "max messages",
+			opts: SearchOptions{MaxMessages: 1},
+			want: []string{"early"},
+		},
+		{
+			name: "exclude",
+			opts: SearchOptions{ExcludeUUIDs: []string{"late"}},
+			want: []string{"early"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Query = "zeebe worker"
+			tt.opts.Scope = ScopeAllProjects
+
+			page, err := db.Search(tt.opts)
+			if err != nil {
+				t.Fatalf("failed to search: %v", err)
+			}
+			matches := page.Matches
+
+			got := map[string]bool{}
+			for _, match := range matches {
+				got[match.UUID] = true
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected matches %v, got %v", tt.want, got)
+			}
+			for _, uuid := range tt.want {
+				if !got[uuid] {
+					t.Errorf("expected match %q, got %v", uuid, got)
+				}
+			}
+		})
+	}
+
+	// Excerpts honour the role filter too
+	page, err := db.Search(SearchOptions{Query: "zeebe", Scope: ScopeAllProjects, Roles: []string{"tool"}})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	matches := page.Matches
+	if len(matches) != 1 || len(matches[0].Excerpts) != 1 || matches[0].Excerpts[0].Role != "tool" {
+		t.Errorf("expected a single tool excerpt, got %+v", matches)
+	}
+
+	// Each match on a page gets its own summary and excerpts
+	for _, excerpts := range []int{2, -1} {
+		page, err = db.Search(SearchOptions{Query: "zeebe", Scope: ScopeAllProjects, Excerpts: excerpts})
+		if err != nil {
+			t.Fatalf("failed to search: %v", err)
+		}
+		want := map[string]struct {
+			summary  string
+			excerpts int
+		}{
+			"early": {"deploy the zeebe worker", 1},
+			"late":  {"why did the zeebe worker fail", 2},
+		}
+		if len(page.Matches) != len(want) {
+			t.Fatalf("expected %d matches, got %+v", len(want), page.Matches)
+		}
+		for _, match := range page.Matches {
+			w := want[match.UUID]
+			if excerpts < 0 {
+				w.excerpts = 0
+			}
+			if match.Summary != w.summary || len(match.Excerpts) != w.excerpts {
+				t.Errorf("with %d excerpts, expected %s to have summary %q and %d excerpts, got %q and %+v",
+					excerpts, match.UUID, w.summary, w.excerpts, match.Summary, match.Excerpts)
+			}
+		}
+	}
+}
+
+func TestSQLiteDB_SearchMessages(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.InitSchema(); err != nil {
+		t.Fatalf("failed to initialize schema: %v", err)
+	}
+
+	conv := &Conversation{
+		UUID:           "long-conv",
+		ProjectPath:    "/Users/test/project",
+		EncodedPath:    "-Users-test-project",
+		CreatedAt:      time.Now(),
+		LastUpdated:    time.Now(),
+		TranscriptPath: "/home/test/.claude/projects/-Users-test-project/long-conv.jsonl",
+	}
+	if err := db.SaveConversation(conv); err != nil {
+		t.Fatalf("failed to save conversation: %v", err)
+	}
+
+	var messages []Message
+	for i := 1; i <= 300; i++ {
+		content := "routine chatter"
+		if i == 142 {
+			content = "the deadlock comes from the connection pool"
+		}
+		messages = append(messages, Message{
+			ConversationUUID: "long-conv",
+			Timestamp:        time.Now(),
+			Role:             "assistant",
+			Content:          content,
+			Line:             i,
+		})
+	}
+	if err := db.SaveMessages(messages); err != nil {
+		t.Fatalf("failed to save messages: %v", err)
+	}
+
+	messagePage, err := db.SearchMessages(SearchOptions{Query: "deadlock", Scope: ScopeAllProjects, Limit: 10})
+	if err != nil {
+		t.Fatalf("failed to search messages: %v", err)
+	}
+	matches := messagePage.Messages
+
+	if len(matches) != 1 {
+		t.Fatalf("expected 1 message match, got %d", len(matches))
+	}
+
+	match := matches[0]
+	if match.ConversationUUID != "long-conv" {
+		t.Errorf("expected conversation 'long-conv', got %q", match.ConversationUUID)
+	}
+	if match.Line != 142 {
+		t.Errorf("expected line 142, got %d", match.Line)
+	}
+	if match.Role != "assistant" {
+		t.Errorf("expected role 'assistant', got %q", match.Role)
+	}
+	if match.TranscriptPath != conv.TranscriptPath {
+		t.Errorf("expected transcript path %q, got %q", conv.TranscriptPath, match.TranscriptPath)
+	}
+	if len(match.Highlights) != 1 || match.Text[match.Highlights[0].Start:match.Highlights[0].End] != "deadlock" {
+		t.Errorf("expected 'deadlock' highlighted in %q, got %v", match.Text, match.Highlights)
+	}
+}
+
+func TestSQLiteDB_ErrorsOnly(t *testing.T) {
+	db := seedRankingDB(t, map[string][]Message{
+		"failed": {
+			{Role: "user", Content: "run the build"},
+			{Role: "tool_result", Content: "build failed: undefined: parseQuery", IsError: true},
+		},
+		"passed": {
+			{Role: "user", Content: "why did the build fail yesterday"},
+			{Role: "tool_result", Content: "build ok"},
+		},
+	}, nil)
+
+	got := searchUUIDs(t, db, SearchOptions{Query: "build"})
+	if len(got) != 2 {
+		t.Errorf("expected both conversations without the filter, got %v", got)
+	}
+
+	page, err := db.Search(SearchOptions{Query: "build", ErrorsOnly: true, Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(page.Matches) != 1 || page.Matches[0].UUID != "failed" || page.Matches[0].Hits != 1 {
+		t.Fatalf("expected one error hit in failed, got %+v", page.Matches)
+	}
+	if excerpt := page.Matches[0].Excerpts[0]; excerpt.Role != "tool_result" || !excerpt.IsError {
+		t.Errorf("expected an error tool_result excerpt, got %+v", excerpt)
+	}
+
+	messages, err := db.SearchMessages(SearchOptions{Query: "parseQuery", Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to search messages: %v", err)
+	}
+	if len(messages.Messages) != 1 || !messages.Messages[0].IsError {
+		t.Errorf("expected the error message flagged, got %+v", messages.Messages)
+	}
+}
+
 func TestSQLiteDB_FileCreation(t *testing.T) {
 	tmpDir := t.TempDir()
 	dbPath := filepath.Join(tmpDir, "new-db.db")
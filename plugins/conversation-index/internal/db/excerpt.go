@@ -0,0 +1,221 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/shared"
+)
+
+// Markers passed to snippet()/highlight() around matched terms. They are
+// control characters that transcripts rarely contain, and stripMarkers
+// removes them from messages before they are stored, so any in an excerpt
+// are ones FTS5 added.
+const (
+	highlightOpen  = "\x02"
+	highlightClose = "\x03"
+)
+
+// markerStripper removes highlight markers from text
+var markerStripper = strings.NewReplacer(highlightOpen, "", highlightClose, "")
+
+// stripMarkers removes highlight markers from message content
+func stripMarkers(content string) string {
+	return markerStripper.Replace(content)
+}
+
+const (
+	// DefaultExcerptsPerMatch is how many excerpts are attached to each match
+	DefaultExcerptsPerMatch = 3
+
+	// excerptTokens is the snippet() window size (FTS5 caps this at 64)
+	excerptTokens = 24
+
+	// Messages at or below this length are highlighted in full instead of snipped
+	fullHighlightLength = 200
+)
+
+// addDetails sets the summary (the first user message) and excerpts of a
+// page of matches with one query. Excerpts are the best matching fragments
+// of a conversation's messages and those of its sidechains, honouring the
+// same message filters as the search itself.
+func (db *sqliteDB) addDetails(opts SearchOptions, matches []Match) error {
+	if len(matches) == 0 {
+		return nil
+	}
+	positions := make(map[string]int, len(matches))
+	uuids := make([]string, len(matches))
+	for i, match := range matches {
+		positions[match.UUID] = i
+		uuids[i] = match.UUID
+	}
+	uuidJSON, err := json.Marshal(uuids)
+	if err != nil {
+		return fmt.Errorf("failed to encode results: %w", err)
+	}
+
+	limit := 0
+	if opts.Excerpts >= 0 {
+		limit = excerptLimit(opts.Excerpts)
+	}
+
+	idx := searchIndex(opts)
+	filters, filterArgs := searchFilters(opts)
+	score, scoreArgs := messageScore(idx, rankingWeights(opts))
+	excerpt, excerptArgs := excerptColumn(idx)
+
+	// Messages are ranked first so only the excerpts shown are marked up
+	sqlQuery := `
+		WITH page(uuid) AS (
+			SELECT value FROM json_each(?)
+		),
+		ranked AS (
+			SELECT r.uuid AS uuid, m.id AS id, ROW_NUMBER() OVER (PARTITION BY r.uuid ORDER BY ` + score + ` DESC) AS n
+			FROM ` + idx.table + `
+			JOIN messages m ON ` + idx.table + `.rowid = m.id
+			JOIN conversations c ON m.conversation_uuid = c.uuid` + rollupJoin + `
+			WHERE ` + idx.table + ` MATCH ? AND r.uuid IN (SELECT uuid FROM page)
+	` + filters + `
+		),
+		excerpts AS (
+			SELECT
+				ranked.uuid,
+				ranked.n,
+				m.role,
+				m.timestamp,
+				COALESCE(m.line, 0) AS line,
+				m.is_error,
+				m.abandoned,
+				m.sidechain,
+				COALESCE(c.subagent_type, '') AS subagent_type,
+				` + excerpt + ` AS marked
+			FROM ` + idx.table + `
+			JOIN ranked ON ranked.id = ` + idx.table + `.rowid
+			JOIN messages m ON m.id = ranked.id
+			JOIN conversations c ON m.conversation_uuid = c.uuid
+			WHERE ` + idx.table + ` MATCH ? AND ranked.n <= ?
+		)
+		SELECT
+			p.uuid,
+			COALESCE((
+				SELECT content FROM messages
+				WHERE conversation_uuid = p.uuid AND role = 'user'
+				ORDER BY timestamp ASC
+				LIMIT 1
+			), ''),
+			e.n IS NOT NULL,
+			COALESCE(e.role, ''),
+			COALESCE(e.timestamp, ''),
+			COALESCE(e.line, 0),
+			COALESCE(e.is_error, 0),
+			COALESCE(e.abandoned, 0),
+			COALESCE(e.sidechain, 0),
+			COALESCE(e.subagent_type, ''),
+			COALESCE(e.marked, '')
+		FROM page p
+		LEFT JOIN excerpts e ON e.uuid = p.uuid
+		ORDER BY p.uuid, e.n
+	`
+
+	args := []interface{}{string(uuidJSON)}
+	args = append(args, scoreArgs...)
+	args = append(args, opts.Query)
+	args = append(args, filterArgs...)
+	args = append(args, excerptArgs...)
+	args = append(args, opts.Query, limit)
+
+	rows, err := db.conn.Query(sqlQuery, args...)
+	if err != nil {
+		return fmt.Errorf("failed to query summaries and excerpts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var uuid, summary, marked string
+		var found bool
+		var excerpt Excerpt
+		if err := rows.Scan(&uuid, &summary, &found, &excerpt.Role, &excerpt.Timestamp, &excerpt.Line, &excerpt.IsError, &excerpt.Abandoned, &excerpt.Sidechain, &excerpt.Subagent, &marked); err != nil {
+			return fmt.Errorf("failed to scan excerpt: %w", err)
+		}
+
+		match := &matches[positions[uuid]]
+		if summary == "" {
+			match.Summary = "No summary available"
+		} else {
+			match.Summary = shared.TruncateString(summary, 150)
+		}
+		if found {
+			excerpt.Text, excerpt.Highlights = parseHighlighted(marked)
+			match.Excerpts = append(match.Excerpts, excerpt)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating excerpts: %w", err)
+	}
+
+	return nil
+}
+
+// excerptColumn returns the SQL expression producing a marked-up excerpt of
+// the current idx row: short messages are highlighted in full and longer
+// ones are cut down to the best matching window
+func excerptColumn(idx ftsIndex) (string, []interface{}) {
+	column := fmt.Sprintf("%s, %d", idx.table, idx.contentColumn)
+	expr := `CASE
+				WHEN length(m.content) <= ? THEN highlight(` + column + `, ?, ?)
+				ELSE snippet(` + column + `, ?, ?, '...', ?)
+			END`
+	args := []interface{}{
+		fullHighlightLength, highlightOpen, highlightClose,
+		highlightOpen, highlightClose, excerptTokens,
+	}
+	return expr, args
+}
+
+// excerptLimit resolves the excerpts-per-match option to a count
+func excerptLimit(n int) int {
+	if n == 0 {
+		return DefaultExcerptsPerMatch
+	}
+	return n
+}
+
+// parseHighlighted strips the highlight markers from FTS5 output, collapsing
+// runs of whitespace so excerpts fit on one line, and returns the byte ranges
+// of the highlighted terms within the cleaned text
+func parseHighlighted(marked string) (string, []Highlight) {
+	var b strings.Builder
+	var highlights []Highlight
+
+	start := -1
+	pendingSpace := false
+
+	for _, r := range marked {
+		switch {
+		case string(r) == highlightOpen:
+			if pendingSpace && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			pendingSpace = false
+			start = b.Len()
+		case string(r) == highlightClose:
+			if start >= 0 && b.Len() > start {
+				highlights = append(highlights, Highlight{Start: start, End: b.Len()})
+			}
+			start = -1
+		case unicode.IsSpace(r):
+			pendingSpace = true
+		default:
+			if pendingSpace && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			pendingSpace = false
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String(), highlights
+}
@@ -0,0 +1,58 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseHighlighted(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		wantText   string
+		wantRanges []Highlight
+	}{
+		{
+			name:       "single term",
+			input:      "a \x02test\x03 message",
+			wantText:   "a test message",
+			wantRanges: []Highlight{{Start: 2, End: 6}},
+		},
+		{
+			name:       "multiple terms",
+			input:      "\x02zeebe\x03 and \x02worker\x03",
+			wantText:   "zeebe and worker",
+			wantRanges: []Highlight{{Start: 0, End: 5}, {Start: 10, End: 16}},
+		},
+		{
+			name:       "whitespace is collapsed",
+			input:      "line one\n\n  \x02line\x03\ttwo",
+			wantText:   "line one line two",
+			wantRanges: []Highlight{{Start: 9, End: 13}},
+		},
+		{
+			name:       "multibyte text",
+			input:      "café \x02naïve\x03",
+			wantText:   "café naïve",
+			wantRanges: []Highlight{{Start: 6, End: 12}},
+		},
+		{
+			name:       "no highlights",
+			input:      "plain text",
+			wantText:   "plain text",
+			wantRanges: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, ranges := parseHighlighted(tt.input)
+			if text != tt.wantText {
+				t.Errorf("text = %q, want %q", text, tt.wantText)
+			}
+			if !reflect.DeepEqual(ranges, tt.wantRanges) {
+				t.Errorf("highlights = %v, want %v", ranges, tt.wantRanges)
+			}
+		})
+	}
+}
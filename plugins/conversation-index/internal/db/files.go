@@ -0,0 +1,119 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FilesTouched lists the conversations with tool calls on opts.Path, or on
+// anything beneath it when it is a directory, most recently touched first
+func (db *sqliteDB) FilesTouched(opts FileOptions) ([]FileActivity, error) {
+	path := cleanFilePath(opts.Path)
+	if path == "" {
+		return nil, fmt.Errorf("file path is required")
+	}
+
+	// A range over the directory prefix uses the file_path index and,
+	// unlike LIKE, is case-sensitive. '0' is the byte after '/'.
+	query := `
+		SELECT
+			t.conversation_uuid,
+			c.project_path,
+			COALESCE(c.transcript_path, ''),
+			COALESCE(c.title, ''),
+			t.tool,
+			t.file_path,
+			t.timestamp,
+			COALESCE(t.line, 0)
+		FROM tool_calls t
+		JOIN conversations c ON t.conversation_uuid = c.uuid
+		WHERE (t.file_path = ? OR (t.file_path >= ? AND t.file_path < ?))
+	`
+	dir := strings.TrimSuffix(path, "/")
+	args := []interface{}{path, dir + "/", dir + "0"}
+
+	if opts.ProjectPath != "" {
+		query += ` AND c.encoded_path = ?`
+		args = append(args, opts.ProjectPath)
+	}
+	// julianday() orders timestamps with and without fractional seconds
+	query += ` ORDER BY julianday(t.timestamp), t.id`
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tool calls: %w", err)
+	}
+	defer rows.Close()
+
+	var touches []fileTouchRow
+	for rows.Next() {
+		var row fileTouchRow
+		err := rows.Scan(
+			&row.activity.UUID,
+			&row.activity.ProjectPath,
+			&row.activity.TranscriptPath,
+			&row.activity.Title,
+			&row.touch.Tool,
+			&row.touch.FilePath,
+			&row.touch.Timestamp,
+			&row.touch.Line,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		touches = append(touches, row)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return groupFileTouches(touches, opts.Limit), nil
+}
+
+// fileTouchRow is a tool call on a file along with its conversation
+type fileTouchRow struct {
+	activity FileActivity
+	touch    FileTouch
+}
+
+// groupFileTouches collects time-ordered tool calls by conversation and
+// orders the conversations by their latest call, newest first
+func groupFileTouches(rows []fileTouchRow, limit int) []FileActivity {
+	byUUID := make(map[string]*FileActivity)
+	for _, row := range rows {
+		activity, exists := byUUID[row.activity.UUID]
+		if !exists {
+			a := row.activity
+			activity = &a
+			byUUID[a.UUID] = activity
+		}
+		activity.Touches = append(activity.Touches, row.touch)
+		activity.LastTouched = row.touch.Timestamp
+	}
+
+	// Walking the calls backwards meets each conversation at its latest call
+	activities := []FileActivity{}
+	for i := len(rows) - 1; i >= 0; i-- {
+		activity, pending := byUUID[rows[i].activity.UUID]
+		if !pending {
+			continue
+		}
+		activities = append(activities, *activity)
+		delete(byUUID, activity.UUID)
+		if limit > 0 && len(activities) == limit {
+			break
+		}
+	}
+
+	return activities
+}
+
+// cleanFilePath trims a path argument, keeping a lone "/"
+func cleanFilePath(path string) string {
+	path = strings.TrimSpace(path)
+	if len(path) > 1 {
+		path = strings.TrimSuffix(path, "/")
+	}
+	return path
+}
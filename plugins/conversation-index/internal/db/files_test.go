@@ -0,0 +1,115 @@
+package db
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSQLiteDB_FilesTouched(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.InitSchema(); err != nil {
+		t.Fatalf("failed to initialize schema: %v", err)
+	}
+
+	base := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	seed := []struct {
+		uuid    string
+		project string
+		calls   []ToolCall
+	}{
+		{"old-edit", "/code/app", []ToolCall{
+			{Tool: "Edit", FilePath: "/code/app/internal/db/db.go", Timestamp: base},
+		}},
+		{"recent-read", "/code/app", []ToolCall{
+			{Tool: "Read", FilePath: "/code/app/internal/db/db.go", Timestamp: base.Add(2 * time.Hour)},
+			{Tool: "Bash", Command: "go test ./...", Timestamp: base.Add(3 * time.Hour)},
+		}},
+		{"sibling", "/code/app", []ToolCall{
+			{Tool: "Write", FilePath: "/code/app/internal/dbx/new.go", Timestamp: base.Add(4 * time.Hour)},
+		}},
+		{"other-project", "/code/lib", []ToolCall{
+			{Tool: "Write", FilePath: "/code/app/internal/db/types.go", Timestamp: base.Add(time.Hour)},
+		}},
+	}
+	for _, s := range seed {
+		conv := &Conversation{
+			UUID:        s.uuid,
+			ProjectPath: s.project,
+			EncodedPath: "-" + filepath.Base(s.project),
+			CreatedAt:   base,
+			LastUpdated: base,
+		}
+		if err := db.SaveConversation(conv); err != nil {
+			t.Fatalf("failed to save conversation: %v", err)
+		}
+		for i := range s.calls {
+			s.calls[i].ConversationUUID = s.uuid
+			s.calls[i].Line = i + 1
+		}
+		if err := db.SaveToolCalls(s.calls); err != nil {
+			t.Fatalf("failed to save tool calls: %v", err)
+		}
+	}
+
+	uuids := func(opts FileOptions) []string {
+		t.Helper()
+		activities, err := db.FilesTouched(opts)
+		if err != nil {
+			t.Fatalf("failed to list files: %v", err)
+		}
+		var got []string
+		for _, a := range activities {
+			got = append(got, a.UUID)
+		}
+		return got
+	}
+
+	tests := []struct {
+		name string
+		opts FileOptions
+		want []string
+	}{
+		{"file", FileOptions{Path: "/code/app/internal/db/db.go"}, []string{"recent-read", "old-edit"}},
+		{"directory", FileOptions{Path: "/code/app/internal/db"}, []string{"recent-read", "other-project", "old-edit"}},
+		{"directory with slash", FileOptions{Path: "/code/app/internal/db/"}, []string{"recent-read", "other-project", "old-edit"}},
+		{"parent directory", FileOptions{Path: "/code/app/internal"}, []string{"sibling", "recent-read", "other-project", "old-edit"}},
+		{"project", FileOptions{Path: "/code/app/internal/db", ProjectPath: "-lib"}, []string{"other-project"}},
+		{"limit", FileOptions{Path: "/code/app", Limit: 2}, []string{"sibling", "recent-read"}},
+		{"case-sensitive", FileOptions{Path: "/code/app/internal/DB"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := uuids(tt.opts); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	activities, err := db.FilesTouched(FileOptions{Path: "/code/app/internal/db/db.go"})
+	if err != nil {
+		t.Fatalf("failed to list files: %v", err)
+	}
+	want := FileTouch{Tool: "Read", FilePath: "/code/app/internal/db/db.go", Timestamp: "2026-01-05T12:00:00Z", Line: 1}
+	if len(activities[0].Touches) != 1 || activities[0].Touches[0] != want {
+		t.Errorf("expected touch %+v, got %+v", want, activities[0].Touches)
+	}
+
+	// Deleting a conversation drops its tool calls
+	if err := db.DeleteConversation("recent-read"); err != nil {
+		t.Fatalf("failed to delete conversation: %v", err)
+	}
+	if got := uuids(FileOptions{Path: "/code/app/internal/db/db.go"}); !reflect.DeepEqual(got, []string{"old-edit"}) {
+		t.Errorf("expected only old-edit after delete, got %v", got)
+	}
+
+	if _, err := db.FilesTouched(FileOptions{Path: " "}); err == nil {
+		t.Error("expected an error for an empty path")
+	}
+}
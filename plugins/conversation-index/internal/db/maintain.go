@@ -0,0 +1,77 @@
+package db
+
+import "fmt"
+
+// ftsTables are the full-text indexes kept in sync with messages
+var ftsTables = []string{"messages_fts", "messages_trigram"}
+
+// Maintain checks and compacts the database. Each search index is checked
+// against the messages table and rebuilt if the check fails, then merged
+// into as few b-trees as possible. Message counts that don't match the
+// messages table are corrected, and the database is analyzed and vacuumed.
+func (db *sqliteDB) Maintain() (*MaintenanceReport, error) {
+	report := &MaintenanceReport{}
+
+	var err error
+	if report.SizeBefore, err = db.size(); err != nil {
+		return nil, err
+	}
+
+	for _, table := range ftsTables {
+		// With rank 1 the index is also checked against its content table
+		_, err := db.conn.Exec(`INSERT INTO ` + table + `(` + table + `, rank) VALUES ('integrity-check', 1)`)
+		if err != nil {
+			report.IndexProblems = append(report.IndexProblems, fmt.Sprintf("%s: %v", table, err))
+			if _, err := db.conn.Exec(`INSERT INTO ` + table + `(` + table + `) VALUES ('rebuild')`); err != nil {
+				return nil, fmt.Errorf("failed to rebuild %s: %w", table, err)
+			}
+		}
+
+		if _, err := db.conn.Exec(`INSERT INTO ` + table + `(` + table + `) VALUES ('optimize')`); err != nil {
+			return nil, fmt.Errorf("failed to optimize %s: %w", table, err)
+		}
+	}
+
+	result, err := db.conn.Exec(`
+		UPDATE conversations
+		SET message_count = (
+			SELECT COUNT(*) FROM messages
+			WHERE conversation_uuid = conversations.uuid AND role != 'title'
+		)
+		WHERE message_count != (
+			SELECT COUNT(*) FROM messages
+			WHERE conversation_uuid = conversations.uuid AND role != 'title'
+		)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fix message counts: %w", err)
+	}
+	fixed, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count fixed conversations: %w", err)
+	}
+	report.CountsFixed = int(fixed)
+
+	if _, err := db.conn.Exec(`ANALYZE`); err != nil {
+		return nil, fmt.Errorf("failed to analyze database: %w", err)
+	}
+	if _, err := db.conn.Exec(`VACUUM`); err != nil {
+		return nil, fmt.Errorf("failed to vacuum database: %w", err)
+	}
+
+	if report.SizeAfter, err = db.size(); err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// size returns the size of the database in bytes
+func (db *sqliteDB) size() (int64, error) {
+	var size int64
+	err := db.conn.QueryRow(`SELECT page_count * page_size FROM pragma_page_count(), pragma_page_size()`).Scan(&size)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get database size: %w", err)
+	}
+	return size, nil
+}
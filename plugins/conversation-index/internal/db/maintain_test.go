@@ -0,0 +1,85 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteDB_Maintain(t *testing.T) {
+	database, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	if err := database.InitSchema(); err != nil {
+		t.Fatalf("failed to initialize schema: %v", err)
+	}
+
+	conv := &Conversation{
+		UUID:        "conv-1",
+		ProjectPath: "/Users/test/project",
+		EncodedPath: "-Users-test-project",
+		CreatedAt:   time.Now(),
+		LastUpdated: time.Now(),
+	}
+	if err := database.SaveConversation(conv); err != nil {
+		t.Fatalf("failed to save conversation: %v", err)
+	}
+	if err := database.SaveMessages([]Message{
+		{ConversationUUID: "conv-1", Timestamp: time.Now(), Role: "user", Content: "compact the index"},
+		{ConversationUUID: "conv-1", Timestamp: time.Now(), Role: "assistant", Content: "vacuum reclaims free pages"},
+	}); err != nil {
+		t.Fatalf("failed to save messages: %v", err)
+	}
+
+	conn := database.(*sqliteDB).conn
+
+	var timeout int
+	if err := conn.QueryRow(`PRAGMA busy_timeout`).Scan(&timeout); err != nil {
+		t.Fatalf("failed to read busy timeout: %v", err)
+	}
+	if timeout != 5000 {
+		t.Errorf("expected a 5000ms busy timeout, got %d", timeout)
+	}
+
+	// A wrong count and a trigram entry without a message
+	if _, err := conn.Exec(`UPDATE conversations SET message_count = 7`); err != nil {
+		t.Fatalf("failed to break message count: %v", err)
+	}
+	if _, err := conn.Exec(`INSERT INTO messages_trigram(rowid, content) VALUES (999, 'ghost message')`); err != nil {
+		t.Fatalf("failed to break trigram index: %v", err)
+	}
+
+	report, err := database.Maintain()
+	if err != nil {
+		t.Fatalf("failed to maintain database: %v", err)
+	}
+	if report.CountsFixed != 1 {
+		t.Errorf("expected 1 count fixed, got %d", report.CountsFixed)
+	}
+	if len(report.IndexProblems) != 1 {
+		t.Errorf("expected a problem with the trigram index, got %v", report.IndexProblems)
+	}
+	if report.SizeBefore <= 0 || report.SizeAfter <= 0 {
+		t.Errorf("expected database sizes, got %d and %d", report.SizeBefore, report.SizeAfter)
+	}
+
+	page, err := database.SearchMessages(SearchOptions{Query: "ghost", Substring: true, Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(page.Messages) != 0 {
+		t.Errorf("expected the rebuilt index to drop the ghost entry, got %+v", page.Messages)
+	}
+
+	// A healthy database needs no fixes
+	report, err = database.Maintain()
+	if err != nil {
+		t.Fatalf("failed to maintain database again: %v", err)
+	}
+	if report.CountsFixed != 0 || len(report.IndexProblems) != 0 {
+		t.Errorf("expected nothing to fix, got %+v", report)
+	}
+}
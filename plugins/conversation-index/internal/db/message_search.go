@@ -0,0 +1,140 @@
+package db
+
+import "fmt"
+
+// SearchMessages performs an FTS5 search returning individual matching
+// messages, best first, so a long conversation can be opened at the exact
+// exchange that matched. Limit caps the number of messages per page.
+func (db *sqliteDB) SearchMessages(opts SearchOptions) (*MessagePage, error) {
+	opts, page, err := startPage(opts, db.latestMessageID)
+	if err != nil {
+		return nil, err
+	}
+	input := opts.Query
+	opts, err = compileSearch(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	idx := searchIndex(opts)
+	filters, filterArgs := searchFilters(opts)
+	if opts.HideSidechainOnly {
+		filters += " AND m.sidechain = 0"
+	}
+	score, scoreArgs := messageScore(idx, rankingWeights(opts))
+	excerpt, excerptArgs := excerptColumn(idx)
+
+	from := `
+		FROM ` + idx.table + `
+		JOIN messages m ON ` + idx.table + `.rowid = m.id
+		JOIN conversations c ON m.conversation_uuid = c.uuid` + rollupJoin + `
+		WHERE ` + idx.table + ` MATCH ?
+	` + filters
+	fromArgs := append([]interface{}{opts.Query}, filterArgs...)
+
+	var total int
+	if err := db.conn.QueryRow(`SELECT COUNT(*)`+from, fromArgs...).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count message results: %w", err)
+	}
+	if total == 0 {
+		suggestions, err := db.suggestions(input, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &MessagePage{PageInfo: pageInfo(page, 0, 0, false, nil), Messages: []MessageMatch{}, Suggestions: suggestions}, nil
+	}
+
+	// Later pages start after the previous page's last result
+	after, offset := "", page.Offset
+	var afterArgs []interface{}
+	if page.After != nil {
+		after = "WHERE relevance_score < ? OR (relevance_score = ? AND id > ?)"
+		afterArgs = []interface{}{page.After.Score, page.After.Score, page.After.ID}
+		offset = 0
+	}
+
+	sqlQuery := `
+		SELECT * FROM (
+		SELECT
+			m.id,
+			c.uuid,
+			COALESCE(c.parent_uuid, ''),
+			c.project_path,
+			COALESCE(c.transcript_path, ''),
+			m.role,
+			m.timestamp,
+			COALESCE(m.line, 0),
+			m.is_error,
+			m.abandoned,
+			m.sidechain,
+			COALESCE(c.subagent_type, ''),
+			` + excerpt + `,
+			` + score + ` AS relevance_score
+	` + from + `
+		)
+		` + after + `
+		ORDER BY relevance_score DESC, id
+		LIMIT ? OFFSET ?
+	`
+
+	args := append([]interface{}{}, excerptArgs...)
+	args = append(args, scoreArgs...)
+	args = append(args, fromArgs...)
+	args = append(args, afterArgs...)
+	args = append(args, keysetLimit(opts.Limit), offset)
+
+	rows, err := db.conn.Query(sqlQuery, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute message search: %w", err)
+	}
+	defer rows.Close()
+
+	matches := []MessageMatch{}
+	var ids []int64
+	for rows.Next() {
+		var match MessageMatch
+		var id int64
+		var marked string
+		err := rows.Scan(
+			&id,
+			&match.ConversationUUID,
+			&match.ParentUUID,
+			&match.ProjectPath,
+			&match.TranscriptPath,
+			&match.Role,
+			&match.Timestamp,
+			&match.Line,
+			&match.IsError,
+			&match.Abandoned,
+			&match.Sidechain,
+			&match.Subagent,
+			&marked,
+			&match.RelevanceScore,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		match.Text, match.Highlights = parseHighlighted(marked)
+		matches = append(matches, match)
+		ids = append(ids, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	more := opts.Limit > 0 && len(matches) > opts.Limit
+	if more {
+		matches = matches[:opts.Limit]
+	}
+	var last *pageKey
+	if n := len(matches); n > 0 {
+		last = &pageKey{Score: matches[n-1].RelevanceScore, ID: ids[n-1]}
+	}
+
+	return &MessagePage{
+		PageInfo: pageInfo(page, total, len(matches), more, last),
+		Messages: matches,
+	}, nil
+}
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestSQLiteDB_SessionMetadataFilters(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.InitSchema(); err != nil {
+		t.Fatalf("failed to initialize schema: %v", err)
+	}
+
+	conversations := []struct {
+		uuid   string
+		branch string
+		model  string
+	}{
+		{"feature-opus", "feature/x", "claude-opus-4-1"},
+		{"feature-sonnet", "feature/x", "claude-sonnet-4-5"},
+		{"main-sonnet", "main", "claude-sonnet-4-5"},
+	}
+	for _, c := range conversations {
+		conv := &Conversation{
+			UUID:            c.uuid,
+			ProjectPath:     "/Users/test/project",
+			EncodedPath:     "-Users-test-project",
+			CreatedAt:       time.Now(),
+			LastUpdated:     time.Now(),
+			SessionMetadata: SessionMetadata{SessionID: c.uuid, GitBranch: c.branch, ClaudeVersion: "2.0.14", Model: c.model},
+		}
+		if err := db.SaveConversation(conv); err != nil {
+			t.Fatalf("failed to save conversation: %v", err)
+		}
+		messages := []Message{
+			{ConversationUUID: c.uuid, Timestamp: time.Now(), Role: "user", Content: "run the migration"},
+			{ConversationUUID: c.uuid, Timestamp: time.Now(), Role: "assistant", Content: "migration applied", Model: c.model},
+		}
+		if err := db.SaveMessages(messages); err != nil {
+			t.Fatalf("failed to save messages: %v", err)
+		}
+	}
+
+	// Saving without metadata keeps what was recorded
+	if err := db.SaveConversation(&Conversation{UUID: "main-sonnet", ProjectPath: "/Users/test/project", EncodedPath: "-Users-test-project", CreatedAt: time.Now(), LastUpdated: time.Now()}); err != nil {
+		t.Fatalf("failed to save conversation: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts SearchOptions
+		want []string
+	}{
+		{"branch flag", SearchOptions{Query: "migration", Branches: []string{"feature/x"}}, []string{"feature-opus", "feature-sonnet"}},
+		{"branch field", SearchOptions{Query: "migration branch:main"}, []string{"main-sonnet"}},
+		{"model flag", SearchOptions{Query: "migration", Models: []string{"opus"}}, []string{"feature-opus"}},
+		{"model field", SearchOptions{Query: "migration model:SONNET"}, []string{"feature-sonnet", "main-sonnet"}},
+		{"branch and model", SearchOptions{Query: "migration", Branches: []string{"feature/x"}, Models: []string{"sonnet"}}, []string{"feature-sonnet"}},
+		{"unknown branch", SearchOptions{Query: "migration", Branches: []string{"release"}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Scope = ScopeAllProjects
+			page, err := db.Search(tt.opts)
+			if err != nil {
+				t.Fatalf("failed to search: %v", err)
+			}
+
+			var got []string
+			for _, match := range page.Matches {
+				got = append(got, match.UUID)
+				// The model filter keeps user messages of matching conversations
+				if match.Hits != 2 {
+					t.Errorf("expected 2 hits for %s, got %d", match.UUID, match.Hits)
+				}
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	page, err := db.Search(SearchOptions{Query: "migration branch:main", Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if match := page.Matches[0]; match.GitBranch != "main" || match.Model != "claude-sonnet-4-5" {
+		t.Errorf("expected branch and model on match, got %q and %q", match.GitBranch, match.Model)
+	}
+}
@@ -0,0 +1,437 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/shared"
+)
+
+// Migration is a single, ordered schema change. Migrations are applied in
+// version order, each in its own transaction, and recorded in schema_version.
+// Once released, a migration must never be edited; add a new one instead.
+type Migration struct {
+	Version     int
+	Description string
+	Statements  []string
+}
+
+// migrations is the ordered registry of schema changes
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Create conversations, messages, messages_fts and index_state",
+		// Matches the schema from before versioning existed, so existing
+		// databases adopt version 1 without changes
+		Statements: []string{
+			`CREATE TABLE IF NOT EXISTS conversations (
+				uuid TEXT PRIMARY KEY,
+				project_path TEXT NOT NULL,
+				encoded_path TEXT NOT NULL,
+				created_at TEXT NOT NULL,
+				last_updated TEXT NOT NULL,
+				message_count INTEGER DEFAULT 0
+			)`,
+			`CREATE TABLE IF NOT EXISTS messages (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				conversation_uuid TEXT NOT NULL,
+				timestamp TEXT NOT NULL,
+				role TEXT NOT NULL,
+				content TEXT,
+				FOREIGN KEY (conversation_uuid) REFERENCES conversations(uuid)
+			)`,
+			`CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
+				conversation_uuid,
+				content,
+				content=messages,
+				content_rowid=id
+			)`,
+			`CREATE TABLE IF NOT EXISTS index_state (
+				conversation_uuid TEXT PRIMARY KEY,
+				last_indexed_line INTEGER DEFAULT 0,
+				last_modified_time TEXT
+			)`,
+			`CREATE TRIGGER IF NOT EXISTS messages_ai AFTER INSERT ON messages BEGIN
+				INSERT INTO messages_fts(rowid, conversation_uuid, content)
+				VALUES (new.id, new.conversation_uuid, new.content);
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS messages_ad AFTER DELETE ON messages BEGIN
+				DELETE FROM messages_fts WHERE rowid = old.id;
+			END`,
+			`CREATE TRIGGER IF NOT EXISTS messages_au AFTER UPDATE ON messages BEGIN
+				DELETE FROM messages_fts WHERE rowid = old.id;
+				INSERT INTO messages_fts(rowid, conversation_uuid, content)
+				VALUES (new.id, new.conversation_uuid, new.content);
+			END`,
+		},
+	},
+	{
+		Version:     2,
+		Description: "Index messages by conversation and conversations by project",
+		Statements: []string{
+			`CREATE INDEX IF NOT EXISTS idx_messages_conversation ON messages(conversation_uuid, timestamp)`,
+			`CREATE INDEX IF NOT EXISTS idx_conversations_encoded_path ON conversations(encoded_path)`,
+		},
+	},
+	{
+		Version:     3,
+		Description: "Fix messages_fts delete/update triggers and drop stale FTS entries",
+		// An external content FTS table can't look up a row's old values once
+		// the row is gone, so they have to be passed to the 'delete' command.
+		// The rebuild drops entries the old triggers left behind.
+		Statements: []string{
+			`DROP TRIGGER IF EXISTS messages_ad`,
+			`DROP TRIGGER IF EXISTS messages_au`,
+			`CREATE TRIGGER messages_ad AFTER DELETE ON messages BEGIN
+				INSERT INTO messages_fts(messages_fts, rowid, conversation_uuid, content)
+				VALUES ('delete', old.id, old.conversation_uuid, old.content);
+			END`,
+			`CREATE TRIGGER messages_au AFTER UPDATE ON messages BEGIN
+				INSERT INTO messages_fts(messages_fts, rowid, conversation_uuid, content)
+				VALUES ('delete', old.id, old.conversation_uuid, old.content);
+				INSERT INTO messages_fts(rowid, conversation_uuid, content)
+				VALUES (new.id, new.conversation_uuid, new.content);
+			END`,
+			`INSERT INTO messages_fts(messages_fts) VALUES ('rebuild')`,
+		},
+	},
+	{
+		Version:     4,
+		Description: "Record transcript line numbers for messages and transcript paths for conversations",
+		Statements: []string{
+			`ALTER TABLE messages ADD COLUMN line INTEGER`,
+			`ALTER TABLE conversations ADD COLUMN transcript_path TEXT`,
+		},
+	},
+	{
+		Version:     5,
+		Description: "Add trigram-tokenized messages_trigram for substring search",
+		Statements: []string{
+			`CREATE VIRTUAL TABLE messages_trigram USING fts5(
+				content,
+				content=messages,
+				content_rowid=id,
+				tokenize='trigram'
+			)`,
+			`CREATE TRIGGER messages_trigram_ai AFTER INSERT ON messages BEGIN
+				INSERT INTO messages_trigram(rowid, content) VALUES (new.id, new.content);
+			END`,
+			`CREATE TRIGGER messages_trigram_ad AFTER DELETE ON messages BEGIN
+				INSERT INTO messages_trigram(messages_trigram, rowid, content)
+				VALUES ('delete', old.id, old.content);
+			END`,
+			`CREATE TRIGGER messages_trigram_au AFTER UPDATE ON messages BEGIN
+				INSERT INTO messages_trigram(messages_trigram, rowid, content)
+				VALUES ('delete', old.id, old.content);
+				INSERT INTO messages_trigram(rowid, content) VALUES (new.id, new.content);
+			END`,
+			`INSERT INTO messages_trigram(messages_trigram) VALUES ('rebuild')`,
+		},
+	},
+	{
+		Version:     6,
+		Description: "Add settings table for index configuration",
+		Statements: []string{
+			`CREATE TABLE settings (
+				key TEXT PRIMARY KEY,
+				value TEXT NOT NULL
+			)`,
+		},
+	},
+	{
+		Version:     7,
+		Description: "Add messages_vocab over messages_fts for search suggestions",
+		Statements: []string{
+			`CREATE VIRTUAL TABLE messages_vocab USING fts5vocab(messages_fts, col)`,
+		},
+	},
+	{
+		Version:     8,
+		Description: "Record conversation titles from summary entries",
+		Statements: []string{
+			`ALTER TABLE conversations ADD COLUMN title TEXT`,
+		},
+	},
+	{
+		Version:     9,
+		Description: "Record session metadata for conversations and the model for messages",
+		Statements: []string{
+			`ALTER TABLE conversations ADD COLUMN session_id TEXT`,
+			`ALTER TABLE conversations ADD COLUMN git_branch TEXT`,
+			`ALTER TABLE conversations ADD COLUMN claude_version TEXT`,
+			`ALTER TABLE conversations ADD COLUMN model TEXT`,
+			`ALTER TABLE conversations ADD COLUMN permission_mode TEXT`,
+			`ALTER TABLE messages ADD COLUMN model TEXT`,
+			`CREATE INDEX idx_conversations_git_branch ON conversations(git_branch)`,
+		},
+	},
+	{
+		Version:     10,
+		Description: "Add tool_calls and backfill it from tool messages",
+		// The backfill recovers the tool name, first file path and command
+		// from the flattened "Tool: X File: Y ... Command: Z" messages. A path
+		// containing spaces is cut short; a full reindex records it exactly.
+		Statements: []string{
+			`CREATE TABLE tool_calls (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				conversation_uuid TEXT NOT NULL,
+				timestamp TEXT NOT NULL,
+				tool TEXT NOT NULL,
+				file_path TEXT,
+				command TEXT,
+				pattern TEXT,
+				line INTEGER,
+				FOREIGN KEY (conversation_uuid) REFERENCES conversations(uuid)
+			)`,
+			`CREATE INDEX idx_tool_calls_file_path ON tool_calls(file_path)`,
+			`CREATE INDEX idx_tool_calls_conversation ON tool_calls(conversation_uuid)`,
+			`INSERT INTO tool_calls (conversation_uuid, timestamp, tool, file_path, command, line)
+			SELECT
+				conversation_uuid,
+				timestamp,
+				substr(tool_rest, 1, instr(tool_rest || ' ', ' ') - 1),
+				substr(file_rest, 1, instr(file_rest || ' ', ' ') - 1),
+				CASE WHEN instr(content, ' Command: ') > 0
+					THEN substr(content, instr(content, ' Command: ') + 10) END,
+				line
+			FROM (
+				SELECT
+					conversation_uuid,
+					timestamp,
+					content,
+					line,
+					substr(content, 7) AS tool_rest,
+					CASE WHEN instr(content, ' File: ') > 0
+						THEN substr(content, instr(content, ' File: ') + 7) END AS file_rest
+				FROM messages
+				WHERE role = 'tool' AND content LIKE 'Tool: %'
+				ORDER BY id
+			)`,
+		},
+	},
+	{
+		Version:     11,
+		Description: "Flag tool results that reported an error",
+		Statements: []string{
+			`ALTER TABLE messages ADD COLUMN is_error INTEGER NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		Version:     12,
+		Description: "Add entries for the conversation tree and key messages by entry",
+		// Messages indexed before this have no entry and stay on the active
+		// branch until the conversation is reindexed
+		Statements: []string{
+			`CREATE TABLE entries (
+				conversation_uuid TEXT NOT NULL,
+				uuid TEXT NOT NULL,
+				parent_uuid TEXT,
+				line INTEGER NOT NULL,
+				PRIMARY KEY (conversation_uuid, uuid)
+			)`,
+			`CREATE INDEX idx_entries_line ON entries(conversation_uuid, line)`,
+			`ALTER TABLE messages ADD COLUMN entry_uuid TEXT`,
+			`ALTER TABLE messages ADD COLUMN part INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE messages ADD COLUMN abandoned INTEGER NOT NULL DEFAULT 0`,
+			`CREATE UNIQUE INDEX idx_messages_entry ON messages(conversation_uuid, entry_uuid, part)
+				WHERE entry_uuid IS NOT NULL`,
+		},
+	},
+	{
+		Version:     13,
+		Description: "Link subagent sidechains to their parent conversations",
+		Statements: []string{
+			`ALTER TABLE conversations ADD COLUMN parent_uuid TEXT`,
+			`ALTER TABLE conversations ADD COLUMN subagent_type TEXT`,
+			`CREATE INDEX idx_conversations_parent ON conversations(parent_uuid)`,
+			`ALTER TABLE messages ADD COLUMN sidechain INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE entries ADD COLUMN sidechain INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE tool_calls ADD COLUMN subagent_type TEXT`,
+			`ALTER TABLE tool_calls ADD COLUMN prompt TEXT`,
+		},
+	},
+	{
+		Version:     14,
+		Description: "Record byte offset, size and inode of indexed transcripts",
+		// Transcripts indexed before this are read in full once more
+		Statements: []string{
+			`ALTER TABLE index_state ADD COLUMN byte_offset INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE index_state ADD COLUMN file_size INTEGER NOT NULL DEFAULT 0`,
+			`ALTER TABLE index_state ADD COLUMN inode INTEGER NOT NULL DEFAULT 0`,
+		},
+	},
+	{
+		Version:     15,
+		Description: "Checksum the indexed prefix of transcripts to detect rewrites",
+		// Clearing the offsets has transcripts read in full once more, which
+		// records their checksums
+		Statements: []string{
+			`ALTER TABLE index_state ADD COLUMN prefix_checksum INTEGER NOT NULL DEFAULT 0`,
+			`UPDATE index_state SET byte_offset = 0`,
+		},
+	},
+	{
+		Version:     16,
+		Description: "Record summary entries by leaf so they title the conversation they summarize",
+		// Titles set before this stay until their transcripts are reindexed
+		Statements: []string{
+			`CREATE TABLE summaries (
+				leaf_uuid TEXT PRIMARY KEY,
+				conversation_uuid TEXT NOT NULL,
+				line INTEGER,
+				timestamp TEXT NOT NULL,
+				title TEXT NOT NULL
+			)`,
+			`CREATE INDEX idx_summaries_conversation ON summaries(conversation_uuid)`,
+			`CREATE INDEX idx_entries_uuid ON entries(uuid)`,
+		},
+	},
+	{
+		Version:     17,
+		Description: "Checksum only the start and end of the indexed prefix of transcripts",
+		// As in version 15, transcripts are read in full once more to record
+		// the new checksums
+		Statements: []string{
+			`UPDATE index_state SET byte_offset = 0`,
+		},
+	},
+	{
+		Version:     18,
+		Description: "Strip the characters marking highlights in excerpts from messages",
+		Statements: []string{
+			`UPDATE messages SET content = replace(replace(content, char(2), ''), char(3), '')
+			WHERE instr(content, char(2)) > 0 OR instr(content, char(3)) > 0`,
+		},
+	},
+}
+
+// LatestSchemaVersion is the version a fully migrated database is at
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// ensureVersionTable creates the schema_version bookkeeping table
+func (db *sqliteDB) ensureVersionTable() error {
+	_, err := db.conn.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version INTEGER PRIMARY KEY,
+			description TEXT NOT NULL,
+			applied_at TEXT NOT NULL
+		)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+	return nil
+}
+
+// SchemaVersion returns the highest applied migration version, 0 for a new database
+func (db *sqliteDB) SchemaVersion() (int, error) {
+	if err := db.ensureVersionTable(); err != nil {
+		return 0, err
+	}
+
+	var version int
+	err := db.conn.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+// PendingMigrations returns the migrations not yet applied, in order
+func (db *sqliteDB) PendingMigrations() ([]Migration, error) {
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	return pendingSince(version), nil
+}
+
+// pendingSince returns the registered migrations newer than version
+func pendingSince(version int) []Migration {
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// Migrate applies all pending migrations and returns the ones it applied
+func (db *sqliteDB) Migrate() ([]Migration, error) {
+	pending, err := db.PendingMigrations()
+	if err != nil {
+		return nil, err
+	}
+
+	var applied []Migration
+	for _, m := range pending {
+		ok, err := db.applyMigration(m)
+		if err != nil {
+			return applied, fmt.Errorf("migration %d (%s) failed: %w", m.Version, m.Description, err)
+		}
+		if ok {
+			applied = append(applied, m)
+		}
+	}
+
+	return applied, nil
+}
+
+// applyMigration runs a migration in an immediate transaction, so a second
+// process migrating at the same time waits and then sees it already applied.
+// It reports false if the migration had already been applied.
+func (db *sqliteDB) applyMigration(m Migration) (bool, error) {
+	ctx := context.Background()
+
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			conn.ExecContext(ctx, "ROLLBACK")
+		}
+	}()
+
+	var exists int
+	err = conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_version WHERE version = ?`, m.Version).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check schema version: %w", err)
+	}
+	if exists > 0 {
+		return false, nil
+	}
+
+	for _, stmt := range m.Statements {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return false, err
+		}
+	}
+
+	_, err = conn.ExecContext(ctx,
+		`INSERT INTO schema_version (version, description, applied_at) VALUES (?, ?, ?)`,
+		m.Version, m.Description, shared.FormatTimestamp(time.Now()),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to record schema version: %w", err)
+	}
+
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		return false, fmt.Errorf("failed to commit migration: %w", err)
+	}
+	committed = true
+
+	return true, nil
+}
@@ -0,0 +1,250 @@
+package db
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// legacySchema is the schema InitSchema created before migrations existed
+const legacySchema = `
+	CREATE TABLE IF NOT EXISTS conversations (
+		uuid TEXT PRIMARY KEY,
+		project_path TEXT NOT NULL,
+		encoded_path TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		last_updated TEXT NOT NULL,
+		message_count INTEGER DEFAULT 0
+	);
+
+	CREATE TABLE IF NOT EXISTS messages (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		conversation_uuid TEXT NOT NULL,
+		timestamp TEXT NOT NULL,
+		role TEXT NOT NULL,
+		content TEXT,
+		FOREIGN KEY (conversation_uuid) REFERENCES conversations(uuid)
+	);
+
+	CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
+		conversation_uuid,
+		content,
+		content=messages,
+		content_rowid=id
+	);
+
+	CREATE TABLE IF NOT EXISTS index_state (
+		conversation_uuid TEXT PRIMARY KEY,
+		last_indexed_line INTEGER DEFAULT 0,
+		last_modified_time TEXT
+	);
+
+	CREATE TRIGGER IF NOT EXISTS messages_ai AFTER INSERT ON messages BEGIN
+		INSERT INTO messages_fts(rowid, conversation_uuid, content)
+		VALUES (new.id, new.conversation_uuid, new.content);
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS messages_ad AFTER DELETE ON messages BEGIN
+		DELETE FROM messages_fts WHERE rowid = old.id;
+	END;
+
+	CREATE TRIGGER IF NOT EXISTS messages_au AFTER UPDATE ON messages BEGIN
+		DELETE FROM messages_fts WHERE rowid = old.id;
+		INSERT INTO messages_fts(rowid, conversation_uuid, content)
+		VALUES (new.id, new.conversation_uuid, new.content);
+	END;
+`
+
+// createLegacyDB builds a database the way the pre-migration indexer did,
+// including a deleted message that leaves a stale FTS entry behind
+func createLegacyDB(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatalf("failed to open legacy database: %v", err)
+	}
+	defer conn.Close()
+
+	stmts := []string{
+		legacySchema,
+		`INSERT INTO conversations (uuid, project_path, encoded_path, created_at, last_updated, message_count)
+		 VALUES ('legacy-conv', '/Users/test/project', '-Users-test-project', '2026-01-05T10:00:00Z', '2026-01-05T10:00:00Z', 2)`,
+		`INSERT INTO messages (conversation_uuid, timestamp, role, content)
+		 VALUES ('legacy-conv', '2026-01-05T10:00:00Z', 'user', 'kafka consumer lag')`,
+		`INSERT INTO messages (conversation_uuid, timestamp, role, content)
+		 VALUES ('legacy-conv', '2026-01-05T10:00:01Z', 'assistant', 'obsolete answer about rabbitmq')`,
+		`INSERT INTO messages (conversation_uuid, timestamp, role, content)
+		 VALUES ('legacy-conv', '2026-01-05T10:00:02Z', 'tool', 'Tool: Edit File: /Users/test/project/main.go')`,
+		`DELETE FROM messages WHERE content LIKE 'obsolete%'`,
+		`INSERT INTO index_state (conversation_uuid, last_indexed_line, last_modified_time)
+		 VALUES ('legacy-conv', 2, '2026-01-05T10:00:01Z')`,
+	}
+	for _, stmt := range stmts {
+		if _, err := conn.Exec(stmt); err != nil {
+			t.Fatalf("failed to build legacy database: %v", err)
+		}
+	}
+
+	return path
+}
+
+func TestMigrate_FromLegacySchema(t *testing.T) {
+	path := createLegacyDB(t)
+
+	database, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	version, err := database.SchemaVersion()
+	if err != nil {
+		t.Fatalf("failed to read schema version: %v", err)
+	}
+	if version != 0 {
+		t.Errorf("expected legacy database at version 0, got %d", version)
+	}
+
+	pending, err := database.PendingMigrations()
+	if err != nil {
+		t.Fatalf("failed to list pending migrations: %v", err)
+	}
+	if len(pending) != len(migrations) {
+		t.Errorf("expected %d pending migrations, got %d", len(migrations), len(pending))
+	}
+
+	// Listing pending migrations must not apply them
+	if version, _ := database.SchemaVersion(); version != 0 {
+		t.Errorf("expected version 0 after dry run, got %d", version)
+	}
+
+	applied, err := database.Migrate()
+	if err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("expected %d applied migrations, got %d", len(migrations), len(applied))
+	}
+
+	version, err = database.SchemaVersion()
+	if err != nil {
+		t.Fatalf("failed to read schema version: %v", err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Errorf("expected version %d, got %d", LatestSchemaVersion(), version)
+	}
+
+	// Existing data survives
+	state, err := database.GetIndexState("legacy-conv")
+	if err != nil {
+		t.Fatalf("failed to get index state: %v", err)
+	}
+	if state == nil || state.LastIndexedLine != 2 {
+		t.Errorf("expected index state to survive migration, got %+v", state)
+	}
+
+	page, err := database.Search(SearchOptions{Query: "kafka", Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	matches := page.Matches
+	if len(matches) != 1 || matches[0].UUID != "legacy-conv" {
+		t.Errorf("expected legacy conversation to be searchable, got %+v", matches)
+	}
+
+	// Existing messages are backfilled into the trigram index
+	page, err = database.Search(SearchOptions{Query: "afk", Substring: true, Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to substring search: %v", err)
+	}
+	matches = page.Matches
+	if len(matches) != 1 || matches[0].UUID != "legacy-conv" {
+		t.Errorf("expected legacy conversation to be substring searchable, got %+v", matches)
+	}
+
+	// Existing tool messages are backfilled into tool_calls
+	activities, err := database.FilesTouched(FileOptions{Path: "/Users/test/project/main.go"})
+	if err != nil {
+		t.Fatalf("failed to list files: %v", err)
+	}
+	if len(activities) != 1 || activities[0].Touches[0].Tool != "Edit" {
+		t.Errorf("expected backfilled Edit of main.go, got %+v", activities)
+	}
+
+	// The stale FTS entry from the legacy delete trigger is gone
+	conn := database.(*sqliteDB).conn
+	if _, err := conn.Exec(`INSERT INTO messages_fts(messages_fts, rank) VALUES ('integrity-check', 1)`); err != nil {
+		t.Errorf("expected FTS integrity check to pass after migration: %v", err)
+	}
+
+	var stale int
+	if err := conn.QueryRow(`SELECT COUNT(*) FROM messages_fts WHERE messages_fts MATCH 'rabbitmq'`).Scan(&stale); err != nil {
+		t.Fatalf("failed to query FTS: %v", err)
+	}
+	if stale != 0 {
+		t.Errorf("expected no FTS entries for deleted message, got %d", stale)
+	}
+
+	// Migrating again is a no-op
+	applied, err = database.Migrate()
+	if err != nil {
+		t.Fatalf("failed to re-run migrations: %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("expected no migrations on second run, got %d", len(applied))
+	}
+}
+
+func TestMigrate_TriggersKeepFTSInSync(t *testing.T) {
+	database, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer database.Close()
+
+	if err := database.InitSchema(); err != nil {
+		t.Fatalf("failed to initialize schema: %v", err)
+	}
+
+	conv := &Conversation{
+		UUID:        "conv",
+		ProjectPath: "/test",
+		EncodedPath: "-test",
+		CreatedAt:   time.Now(),
+		LastUpdated: time.Now(),
+	}
+	if err := database.SaveConversation(conv); err != nil {
+		t.Fatalf("failed to save conversation: %v", err)
+	}
+	if err := database.SaveMessages([]Message{
+		{ConversationUUID: "conv", Timestamp: time.Now(), Role: "user", Content: "original wording"},
+	}); err != nil {
+		t.Fatalf("failed to save messages: %v", err)
+	}
+
+	conn := database.(*sqliteDB).conn
+	if _, err := conn.Exec(`UPDATE messages SET content = 'revised wording'`); err != nil {
+		t.Fatalf("failed to update message: %v", err)
+	}
+
+	for query, want := range map[string]int{"original": 0, "revised": 1} {
+		page, err := database.Search(SearchOptions{Query: query, Scope: ScopeAllProjects})
+		if err != nil {
+			t.Fatalf("failed to search %q: %v", query, err)
+		}
+		matches := page.Matches
+		if len(matches) != want {
+			t.Errorf("expected %d matches for %q after update, got %d", want, query, len(matches))
+		}
+	}
+
+	if err := database.DeleteConversation("conv"); err != nil {
+		t.Fatalf("failed to delete conversation: %v", err)
+	}
+	if _, err := conn.Exec(`INSERT INTO messages_fts(messages_fts, rank) VALUES ('integrity-check', 1)`); err != nil {
+		t.Errorf("expected FTS integrity check to pass after delete: %v", err)
+	}
+}
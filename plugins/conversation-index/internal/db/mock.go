@@ -1,12 +1,24 @@
 package db
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/shared"
+)
 
 // MockDB is a simple in-memory mock implementation of the DB interface for testing
 type MockDB struct {
 	conversations map[string]*Conversation
-	messages      map[string][]Message // keyed by conversation UUID
+	messages      map[string][]Message   // keyed by conversation UUID
+	toolCalls     map[string][]ToolCall  // keyed by conversation UUID
+	entries       map[string][]Entry     // keyed by conversation UUID
+	summaries     map[string]mockSummary // keyed by leaf UUID
 	indexStates   map[string]*IndexState
+	tokenizer     string
+	lastPruned    time.Time
 }
 
 // NewMock creates a new mock database
@@ -14,23 +26,80 @@ func NewMock() *MockDB {
 	return &MockDB{
 		conversations: make(map[string]*Conversation),
 		messages:      make(map[string][]Message),
+		toolCalls:     make(map[string][]ToolCall),
+		entries:       make(map[string][]Entry),
+		summaries:     make(map[string]mockSummary),
 		indexStates:   make(map[string]*IndexState),
 	}
 }
 
+// mockSummary is a saved summary and when it was saved, so the latest wins
+type mockSummary struct {
+	Summary
+	seq int
+}
+
 func (m *MockDB) InitSchema() error {
 	// No-op for mock
 	return nil
 }
 
+func (m *MockDB) SchemaVersion() (int, error) {
+	return LatestSchemaVersion(), nil
+}
+
+func (m *MockDB) PendingMigrations() ([]Migration, error) {
+	return nil, nil
+}
+
+func (m *MockDB) Migrate() ([]Migration, error) {
+	return nil, nil
+}
+
+func (m *MockDB) Tokenizer() (string, error) {
+	if m.tokenizer == "" {
+		return DefaultTokenizer().String(), nil
+	}
+	return m.tokenizer, nil
+}
+
+func (m *MockDB) SetTokenizer(t Tokenizer) (bool, error) {
+	if err := t.Validate(); err != nil {
+		return false, fmt.Errorf("invalid tokenizer: %w", err)
+	}
+	current, _ := m.Tokenizer()
+	m.tokenizer = t.String()
+	return current != m.tokenizer, nil
+}
+
 func (m *MockDB) TruncateAll() error {
 	m.conversations = make(map[string]*Conversation)
 	m.messages = make(map[string][]Message)
+	m.toolCalls = make(map[string][]ToolCall)
+	m.entries = make(map[string][]Entry)
+	m.summaries = make(map[string]mockSummary)
 	m.indexStates = make(map[string]*IndexState)
 	return nil
 }
 
+// SaveConversation keeps the stored title, message count and any session
+// metadata conv leaves empty, as the SQLite upsert does
 func (m *MockDB) SaveConversation(conv *Conversation) error {
+	if existing, exists := m.conversations[conv.UUID]; exists {
+		conv.CreatedAt = existing.CreatedAt
+		if conv.ProjectPath == "" {
+			conv.ProjectPath = existing.ProjectPath
+		}
+		conv.Title = existing.Title
+		conv.MessageCount = existing.MessageCount
+		if conv.ParentUUID == "" {
+			conv.ParentUUID = existing.ParentUUID
+		}
+		conv.SubagentType = existing.SubagentType
+		session := existing.SessionMetadata
+		session.Merge(conv.SessionMetadata)
+		conv.SessionMetadata = session
+	}
 	m.conversations[conv.UUID] = conv
 	return nil
 }
@@ -41,16 +110,246 @@ func (m *MockDB) SaveMessages(messages []Message) error {
 	}
 
 	uuid := messages[0].ConversationUUID
-	m.messages[uuid] = append(m.messages[uuid], messages...)
+	for _, msg := range messages {
+		m.upsertMessage(uuid, msg)
+	}
+	m.updateMessageCount(uuid)
+
+	return nil
+}
+
+// upsertMessage replaces the message of the same entry and part, keeping
+// its abandoned state, or appends msg
+func (m *MockDB) upsertMessage(uuid string, msg Message) {
+	if msg.EntryUUID != "" {
+		for i, existing := range m.messages[uuid] {
+			if existing.EntryUUID == msg.EntryUUID && existing.Part == msg.Part {
+				msg.Abandoned = existing.Abandoned
+				m.messages[uuid][i] = msg
+				return
+			}
+		}
+	}
+	m.messages[uuid] = append(m.messages[uuid], msg)
+}
+
+// updateMessageCount recounts a conversation's messages, not counting its title
+func (m *MockDB) updateMessageCount(uuid string) {
+	conv, exists := m.conversations[uuid]
+	if !exists {
+		return
+	}
+	conv.MessageCount = 0
+	for _, msg := range m.messages[uuid] {
+		if msg.Role != "title" {
+			conv.MessageCount++
+		}
+	}
+}
+
+func (m *MockDB) SaveEntries(entries []Entry) error {
+	for _, entry := range entries {
+		uuid := entry.ConversationUUID
+		replaced := false
+		for i, existing := range m.entries[uuid] {
+			if existing.UUID == entry.UUID {
+				m.entries[uuid][i] = entry
+				replaced = true
+			}
+		}
+		if !replaced {
+			m.entries[uuid] = append(m.entries[uuid], entry)
+		}
+	}
+	return nil
+}
 
-	// Update message count
+// UpdateActiveBranch walks parents from the entry on the highest line of
+// the conversation's own tree, ignoring sidechain entries in a main
+// transcript and vice versa
+func (m *MockDB) UpdateActiveBranch(uuid string) error {
+	sidechain := false
 	if conv, exists := m.conversations[uuid]; exists {
-		conv.MessageCount += len(messages)
+		sidechain = conv.ParentUUID != ""
+	}
+
+	parents := make(map[string]string)
+	var leaf *Entry
+	for i, entry := range m.entries[uuid] {
+		if entry.Sidechain != sidechain {
+			continue
+		}
+		parents[entry.UUID] = entry.ParentUUID
+		if leaf == nil || entry.Line > leaf.Line {
+			leaf = &m.entries[uuid][i]
+		}
+	}
+	if leaf == nil {
+		return nil
 	}
 
+	active := make(map[string]bool)
+	for id := leaf.UUID; id != "" && !active[id]; id = parents[id] {
+		active[id] = true
+	}
+
+	for i, msg := range m.messages[uuid] {
+		if msg.EntryUUID != "" && msg.Sidechain == sidechain {
+			m.messages[uuid][i].Abandoned = !active[msg.EntryUUID]
+		}
+	}
 	return nil
 }
 
+// LinkSidechains sets the subagent type of sidechains from the parent's
+// Task call whose prompt is the sidechain's first user message
+func (m *MockDB) LinkSidechains() error {
+	for uuid, conv := range m.conversations {
+		if conv.ParentUUID == "" || conv.SubagentType != "" {
+			continue
+		}
+		prompt, _ := m.GetFirstUserMessage(uuid)
+		for _, call := range m.toolCalls[conv.ParentUUID] {
+			if call.SubagentType != "" && call.Prompt == prompt {
+				conv.SubagentType = call.SubagentType
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// withSidechains returns a conversation followed by the sidechains linked to
+// it, or nil if it is itself a sidechain of an indexed conversation
+func (m *MockDB) withSidechains(uuid string) []*Conversation {
+	conv := m.conversations[uuid]
+	if _, exists := m.conversations[conv.ParentUUID]; exists {
+		return nil
+	}
+
+	var sidechains []*Conversation
+	for _, other := range m.conversations {
+		if other.ParentUUID == uuid {
+			sidechains = append(sidechains, other)
+		}
+	}
+	sort.Slice(sidechains, func(i, j int) bool { return sidechains[i].UUID < sidechains[j].UUID })
+	return append([]*Conversation{conv}, sidechains...)
+}
+
+func (m *MockDB) PruneConversation(uuid string, keepEntries []string) error {
+	var messages []Message
+	for _, msg := range m.messages[uuid] {
+		if msg.EntryUUID != "" && containsString(keepEntries, msg.EntryUUID) {
+			messages = append(messages, msg)
+		}
+	}
+	m.messages[uuid] = messages
+
+	var entries []Entry
+	for _, entry := range m.entries[uuid] {
+		if containsString(keepEntries, entry.UUID) {
+			entries = append(entries, entry)
+		}
+	}
+	m.entries[uuid] = entries
+
+	m.deleteSummaries(uuid)
+	delete(m.toolCalls, uuid)
+	if conv, exists := m.conversations[uuid]; exists {
+		conv.Title = ""
+	}
+	m.updateMessageCount(uuid)
+	return nil
+}
+
+func (m *MockDB) SaveToolCalls(calls []ToolCall) error {
+	for _, call := range calls {
+		m.toolCalls[call.ConversationUUID] = append(m.toolCalls[call.ConversationUUID], call)
+	}
+	return nil
+}
+
+// SaveConversations applies each update with the mock's other methods, in
+// the same order as the SQLite implementation
+func (m *MockDB) SaveConversations(updates []ConversationUpdate) error {
+	for _, u := range updates {
+		if u.Conversation == nil {
+			m.UpdateIndexState(u.State)
+			continue
+		}
+		uuid := u.Conversation.UUID
+		m.SaveConversation(u.Conversation)
+		if u.Prune {
+			m.PruneConversation(uuid, u.KeepEntries)
+		}
+		m.SaveEntries(u.Entries)
+		m.SaveMessages(u.Messages)
+		m.SaveToolCalls(u.ToolCalls)
+		m.saveSummaries(u)
+		m.UpdateActiveBranch(uuid)
+		m.UpdateIndexState(u.State)
+	}
+	return nil
+}
+
+// saveSummaries records summaries by leaf and retitles every conversation
+// with a summarized entry from its latest summary
+func (m *MockDB) saveSummaries(u ConversationUpdate) {
+	for _, s := range u.Summaries {
+		if s.LeafUUID == "" {
+			m.SetTitle(s.Title)
+			continue
+		}
+		m.summaries[s.LeafUUID] = mockSummary{Summary: s, seq: m.summarySeq() + 1}
+	}
+
+	for uuid, entries := range m.entries {
+		var latest *mockSummary
+		for _, entry := range entries {
+			if s, ok := m.summaries[entry.UUID]; ok && (latest == nil || s.seq > latest.seq) {
+				latest = &s
+			}
+		}
+		if latest == nil {
+			continue
+		}
+		title := latest.Title
+		if title.ConversationUUID != uuid {
+			title.ConversationUUID = uuid
+			title.Line = 0
+		}
+		m.SetTitle(title)
+	}
+}
+
+// deleteSummaries removes the summaries read from a conversation's transcript
+func (m *MockDB) deleteSummaries(uuid string) {
+	for leaf, s := range m.summaries {
+		if s.Title.ConversationUUID == uuid {
+			delete(m.summaries, leaf)
+		}
+	}
+}
+
+func (m *MockDB) LastPruned() (time.Time, error) {
+	return m.lastPruned, nil
+}
+
+func (m *MockDB) SetLastPruned(t time.Time) error {
+	m.lastPruned = t
+	return nil
+}
+
+// summarySeq returns the highest sequence number given to a summary
+func (m *MockDB) summarySeq() int {
+	seq := 0
+	for _, s := range m.summaries {
+		seq = max(seq, s.seq)
+	}
+	return seq
+}
+
 func (m *MockDB) GetIndexState(uuid string) (*IndexState, error) {
 	state, exists := m.indexStates[uuid]
 	if !exists {
@@ -66,9 +365,30 @@ func (m *MockDB) UpdateIndexState(state *IndexState) error {
 
 func (m *MockDB) DeleteConversation(uuid string) error {
 	delete(m.messages, uuid)
+	delete(m.toolCalls, uuid)
+	delete(m.entries, uuid)
+	m.deleteSummaries(uuid)
 	if conv, exists := m.conversations[uuid]; exists {
 		conv.MessageCount = 0
+		conv.Title = ""
+	}
+	return nil
+}
+
+func (m *MockDB) SetTitle(title Message) error {
+	uuid := title.ConversationUUID
+	if conv, exists := m.conversations[uuid]; exists {
+		conv.Title = title.Content
+	}
+
+	var kept []Message
+	for _, msg := range m.messages[uuid] {
+		if msg.Role != "title" {
+			kept = append(kept, msg)
+		}
 	}
+	title.Role = "title"
+	m.messages[uuid] = append(kept, title)
 	return nil
 }
 
@@ -77,6 +397,25 @@ func (m *MockDB) DeleteIndexState(uuid string) error {
 	return nil
 }
 
+func (m *MockDB) ListConversations() ([]Conversation, error) {
+	var conversations []Conversation
+	for _, conv := range m.conversations {
+		conversations = append(conversations, *conv)
+	}
+	sort.Slice(conversations, func(i, j int) bool { return conversations[i].UUID < conversations[j].UUID })
+	return conversations, nil
+}
+
+func (m *MockDB) RemoveConversation(uuid string) error {
+	delete(m.conversations, uuid)
+	delete(m.messages, uuid)
+	delete(m.toolCalls, uuid)
+	delete(m.entries, uuid)
+	m.deleteSummaries(uuid)
+	delete(m.indexStates, uuid)
+	return nil
+}
+
 func (m *MockDB) GetFirstUserMessage(uuid string) (string, error) {
 	messages, exists := m.messages[uuid]
 	if !exists {
@@ -92,10 +431,461 @@ func (m *MockDB) GetFirstUserMessage(uuid string) (string, error) {
 	return "", nil
 }
 
-func (m *MockDB) Search(query, scope, projectPath string, limit int) ([]Match, error) {
-	// Simple mock: just return empty results
-	// In real tests, you could populate this with test data
-	return []Match{}, nil
+// Search does a case-insensitive substring match of the parsed query terms
+// against message content and applies the same filters as the SQLite
+// implementation. Raw queries are split on whitespace and FTS5 operators are
+// not supported. Each hit scores its role weight in place of bm25.
+func (m *MockDB) Search(opts SearchOptions) (*SearchPage, error) {
+	opts, page, err := startPage(opts, m.latestMessageID)
+	if err != nil {
+		return nil, err
+	}
+	query, err := mockQuery(opts)
+	if err != nil {
+		return nil, err
+	}
+	weights := rankingWeights(opts)
+
+	matches := []Match{}
+	for uuid, conv := range m.conversations {
+		var scores []float64
+		var excerpts []Excerpt
+		sidechainHits := 0
+		for _, source := range m.withSidechains(uuid) {
+			if !m.conversationMatches(source, opts) {
+				continue
+			}
+			for _, msg := range m.messages[source.UUID] {
+				if !m.messageMatches(source, msg, query, opts) {
+					continue
+				}
+				score := 1.0
+				if w, ok := weights.Roles[msg.Role]; ok {
+					score = w
+				}
+				scores = append(scores, score)
+				if msg.Sidechain {
+					sidechainHits++
+				}
+				if opts.Excerpts >= 0 && len(excerpts) < excerptLimit(opts.Excerpts) {
+					excerpts = append(excerpts, Excerpt{
+						Role:      msg.Role,
+						Timestamp: shared.FormatTimestamp(msg.Timestamp),
+						Line:      msg.Line,
+						IsError:   msg.IsError,
+						Abandoned: msg.Abandoned,
+						Sidechain: msg.Sidechain,
+						Subagent:  source.SubagentType,
+						Text:      msg.Content,
+					})
+				}
+			}
+		}
+		if len(scores) == 0 || (opts.HideSidechainOnly && sidechainHits == len(scores)) {
+			continue
+		}
+
+		sort.Sort(sort.Reverse(sort.Float64Slice(scores)))
+		total := 0.0
+		for i, score := range scores {
+			total += score / float64(i+1)
+		}
+
+		summary, _ := m.GetFirstUserMessage(uuid)
+		matches = append(matches, Match{
+			UUID:           conv.UUID,
+			ProjectPath:    conv.ProjectPath,
+			EncodedPath:    conv.EncodedPath,
+			CreatedAt:      shared.FormatTimestamp(conv.CreatedAt),
+			LastUpdated:    shared.FormatTimestamp(conv.LastUpdated),
+			MessageCount:   conv.MessageCount,
+			Title:          conv.Title,
+			GitBranch:      conv.GitBranch,
+			Model:          conv.Model,
+			Summary:        summary,
+			Hits:           len(scores),
+			SidechainHits:  sidechainHits,
+			RelevanceScore: total,
+			Excerpts:       excerpts,
+		})
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].RelevanceScore != matches[j].RelevanceScore {
+			return matches[i].RelevanceScore > matches[j].RelevanceScore
+		}
+		return matches[i].UUID < matches[j].UUID
+	})
+
+	total := len(matches)
+	start, end := pageBounds(total, page.Offset, opts.Limit)
+	matches = matches[start:end]
+
+	return &SearchPage{
+		PageInfo: pageInfo(page, total, len(matches), end < total, nil),
+		Matches:  matches,
+	}, nil
+}
+
+// SearchMessages returns each matching message, scored by its role weight
+func (m *MockDB) SearchMessages(opts SearchOptions) (*MessagePage, error) {
+	opts, page, err := startPage(opts, m.latestMessageID)
+	if err != nil {
+		return nil, err
+	}
+	query, err := mockQuery(opts)
+	if err != nil {
+		return nil, err
+	}
+	weights := rankingWeights(opts)
+
+	matches := []MessageMatch{}
+	for uuid, conv := range m.conversations {
+		if !m.conversationMatches(conv, opts) {
+			continue
+		}
+
+		for _, msg := range m.messages[uuid] {
+			if !m.messageMatches(conv, msg, query, opts) {
+				continue
+			}
+			if opts.HideSidechainOnly && msg.Sidechain {
+				continue
+			}
+
+			score := 1.0
+			if w, ok := weights.Roles[msg.Role]; ok {
+				score = w
+			}
+			matches = append(matches, MessageMatch{
+				ConversationUUID: uuid,
+				ParentUUID:       conv.ParentUUID,
+				ProjectPath:      conv.ProjectPath,
+				TranscriptPath:   conv.TranscriptPath,
+				RelevanceScore:   score,
+				Excerpt: Excerpt{
+					Role:      msg.Role,
+					Timestamp: shared.FormatTimestamp(msg.Timestamp),
+					Line:      msg.Line,
+					IsError:   msg.IsError,
+					Abandoned: msg.Abandoned,
+					Sidechain: msg.Sidechain,
+					Subagent:  conv.SubagentType,
+					Text:      msg.Content,
+				},
+			})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].RelevanceScore != matches[j].RelevanceScore {
+			return matches[i].RelevanceScore > matches[j].RelevanceScore
+		}
+		if matches[i].ConversationUUID != matches[j].ConversationUUID {
+			return matches[i].ConversationUUID < matches[j].ConversationUUID
+		}
+		return matches[i].Line < matches[j].Line
+	})
+
+	total := len(matches)
+	start, end := pageBounds(total, page.Offset, opts.Limit)
+	matches = matches[start:end]
+
+	return &MessagePage{
+		PageInfo: pageInfo(page, total, len(matches), end < total, nil),
+		Messages: matches,
+	}, nil
+}
+
+// pageBounds returns the slice bounds of a page of up to limit results
+// starting at offset, where limit <= 0 means no limit
+func pageBounds(total, offset, limit int) (int, int) {
+	start := offset
+	if start > total {
+		start = total
+	}
+	end := total
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	return start, end
+}
+
+// latestMessageID is a constant snapshot, as the mock has no message ids
+func (m *MockDB) latestMessageID() (int64, error) {
+	return 0, nil
+}
+
+// conversationMatches applies the conversation-level search filters
+func (m *MockDB) conversationMatches(conv *Conversation, opts SearchOptions) bool {
+	if opts.Scope == ScopeCurrentProject && opts.ProjectPath != "" && conv.EncodedPath != opts.ProjectPath {
+		return false
+	}
+	if opts.MinMessages > 0 && conv.MessageCount < opts.MinMessages {
+		return false
+	}
+	if opts.MaxMessages > 0 && conv.MessageCount > opts.MaxMessages {
+		return false
+	}
+	if len(opts.Branches) > 0 && !containsString(opts.Branches, conv.GitBranch) {
+		return false
+	}
+	if len(opts.Models) > 0 && !m.usedModel(conv.UUID, opts.Models) {
+		return false
+	}
+	for _, uuid := range opts.ExcludeUUIDs {
+		if conv.UUID == uuid {
+			return false
+		}
+	}
+	return true
+}
+
+// mockQuery parses the query the way the SQLite implementation does
+func mockQuery(opts SearchOptions) (*ParsedQuery, error) {
+	if !opts.Raw {
+		query, err := ParseQuery(opts.Query, opts.Now)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Substring {
+			if err := substringQuery(query); err != nil {
+				return nil, err
+			}
+		}
+		return query, nil
+	}
+
+	query := &ParsedQuery{}
+	for _, word := range strings.Fields(opts.Query) {
+		query.Terms = append(query.Terms, []QueryTerm{{Text: word}})
+	}
+	return query, nil
+}
+
+// messageMatches applies the query and message-level search filters
+func (m *MockDB) messageMatches(conv *Conversation, msg Message, query *ParsedQuery, opts SearchOptions) bool {
+	if !opts.Since.IsZero() && msg.Timestamp.Before(opts.Since) {
+		return false
+	}
+	if !opts.Until.IsZero() && !msg.Timestamp.Before(opts.Until) {
+		return false
+	}
+	if len(opts.Roles) > 0 && !containsString(opts.Roles, msg.Role) {
+		return false
+	}
+	if opts.ErrorsOnly && !msg.IsError {
+		return false
+	}
+	if !opts.IncludeAbandoned && msg.Abandoned {
+		return false
+	}
+
+	if !query.After.IsZero() && msg.Timestamp.Before(query.After) {
+		return false
+	}
+	if !query.Before.IsZero() && !msg.Timestamp.Before(query.Before) {
+		return false
+	}
+	if len(query.Roles) > 0 && !containsString(query.Roles, msg.Role) {
+		return false
+	}
+
+	if len(query.Branches) > 0 && !containsString(query.Branches, conv.GitBranch) {
+		return false
+	}
+	if len(query.Models) > 0 && !m.usedModel(conv.UUID, query.Models) {
+		return false
+	}
+
+	if len(query.Projects) > 0 {
+		found := false
+		for _, project := range query.Projects {
+			if containsFold(conv.ProjectPath, project) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, tool := range query.Tools {
+		if !m.hasToolCall(msg, func(call ToolCall) bool { return call.Tool == tool }) || !containsFold(msg.Content, "Tool: "+tool) {
+			return false
+		}
+	}
+	for _, file := range query.Files {
+		if !m.hasToolCall(msg, func(call ToolCall) bool { return containsFold(call.FilePath, file) }) || !containsFold(msg.Content, file) {
+			return false
+		}
+	}
+
+	for _, group := range query.Terms {
+		found := false
+		for _, term := range group {
+			if containsFold(msg.Content, term.Text) {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for _, term := range query.Excluded {
+		if containsFold(msg.Content, term.Text) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// hasToolCall reports whether msg is a tool message on the same line as a
+// tool call matching match
+func (m *MockDB) hasToolCall(msg Message, match func(ToolCall) bool) bool {
+	if msg.Role != "tool" {
+		return false
+	}
+	for _, call := range m.toolCalls[msg.ConversationUUID] {
+		if call.Line == msg.Line && match(call) {
+			return true
+		}
+	}
+	return false
+}
+
+// usedModel reports whether any message of a conversation came from a model
+// containing one of models
+func (m *MockDB) usedModel(uuid string, models []string) bool {
+	for _, msg := range m.messages[uuid] {
+		for _, model := range models {
+			if msg.Model != "" && containsFold(msg.Model, model) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsString reports whether list contains s
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// containsFold reports whether substr is within s, ignoring case
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+// FilesTouched matches tool calls on opts.Path or beneath it, like the
+// SQLite implementation
+func (m *MockDB) FilesTouched(opts FileOptions) ([]FileActivity, error) {
+	path := cleanFilePath(opts.Path)
+	if path == "" {
+		return nil, fmt.Errorf("file path is required")
+	}
+	dir := strings.TrimSuffix(path, "/") + "/"
+
+	uuids := make([]string, 0, len(m.toolCalls))
+	for uuid := range m.toolCalls {
+		uuids = append(uuids, uuid)
+	}
+	sort.Strings(uuids)
+
+	var rows []fileTouchRow
+	var times []time.Time
+	for _, uuid := range uuids {
+		conv, exists := m.conversations[uuid]
+		if !exists || (opts.ProjectPath != "" && conv.EncodedPath != opts.ProjectPath) {
+			continue
+		}
+		for _, call := range m.toolCalls[uuid] {
+			if call.FilePath != path && !strings.HasPrefix(call.FilePath, dir) {
+				continue
+			}
+			times = append(times, call.Timestamp)
+			rows = append(rows, fileTouchRow{
+				activity: FileActivity{
+					UUID:           uuid,
+					ProjectPath:    conv.ProjectPath,
+					TranscriptPath: conv.TranscriptPath,
+					Title:          conv.Title,
+				},
+				touch: FileTouch{
+					Tool:      call.Tool,
+					FilePath:  call.FilePath,
+					Timestamp: shared.FormatTimestamp(call.Timestamp),
+					Line:      call.Line,
+				},
+			})
+		}
+	}
+	sort.Stable(byTime{rows, times})
+
+	return groupFileTouches(rows, opts.Limit), nil
+}
+
+// byTime sorts tool call rows by their parallel call times
+type byTime struct {
+	rows  []fileTouchRow
+	times []time.Time
+}
+
+func (b byTime) Len() int           { return len(b.rows) }
+func (b byTime) Less(i, j int) bool { return b.times[i].Before(b.times[j]) }
+func (b byTime) Swap(i, j int) {
+	b.rows[i], b.rows[j] = b.rows[j], b.rows[i]
+	b.times[i], b.times[j] = b.times[j], b.times[i]
+}
+
+func (m *MockDB) RewriteContent(rewrite func(string) string) (int, error) {
+	changed := 0
+	apply := func(s *string) {
+		if rewritten := rewrite(*s); rewritten != *s {
+			*s = rewritten
+			changed++
+		}
+	}
+	for uuid := range m.messages {
+		for i := range m.messages[uuid] {
+			apply(&m.messages[uuid][i].Content)
+		}
+	}
+	for _, conv := range m.conversations {
+		if conv.Title != "" {
+			apply(&conv.Title)
+		}
+	}
+	for uuid := range m.toolCalls {
+		for i := range m.toolCalls[uuid] {
+			call := &m.toolCalls[uuid][i]
+			for _, s := range []*string{&call.Command, &call.Pattern, &call.Prompt} {
+				if *s != "" {
+					apply(s)
+				}
+			}
+		}
+	}
+	return changed, nil
+}
+
+// Maintain corrects message counts; the mock has no size or search indexes
+func (m *MockDB) Maintain() (*MaintenanceReport, error) {
+	report := &MaintenanceReport{}
+	for uuid, conv := range m.conversations {
+		before := conv.MessageCount
+		m.updateMessageCount(uuid)
+		if conv.MessageCount != before {
+			report.CountsFixed++
+		}
+	}
+	return report, nil
 }
 
 func (m *MockDB) Close() error {
@@ -117,3 +907,8 @@ func (m *MockDB) GetConversation(uuid string) (*Conversation, error) {
 func (m *MockDB) GetMessages(uuid string) []Message {
 	return m.messages[uuid]
 }
+
+// GetToolCalls retrieves all tool calls for a conversation
+func (m *MockDB) GetToolCalls(uuid string) []ToolCall {
+	return m.toolCalls[uuid]
+}
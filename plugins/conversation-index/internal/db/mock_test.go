@@ -0,0 +1,67 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMockDB_SearchOptions(t *testing.T) {
+	mock := NewMock()
+
+	now := time.Now()
+	for _, conv := range []*Conversation{
+		{UUID: "a", EncodedPath: "-project-a"},
+		{UUID: "b", EncodedPath: "-project-b"},
+	} {
+		if err := mock.SaveConversation(conv); err != nil {
+			t.Fatalf("failed to save conversation: %v", err)
+		}
+	}
+
+	if err := mock.SaveMessages([]Message{
+		{ConversationUUID: "a", Timestamp: now.Add(-48 * time.Hour), Role: "user", Content: "Zeebe worker setup"},
+	}); err != nil {
+		t.Fatalf("failed to save messages: %v", err)
+	}
+	if err := mock.SaveMessages([]Message{
+		{ConversationUUID: "b", Timestamp: now, Role: "assistant", Content: "the zeebe worker crashed"},
+		{ConversationUUID: "b", Timestamp: now, Role: "user", Content: "thanks"},
+	}); err != nil {
+		t.Fatalf("failed to save messages: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		opts SearchOptions
+		want []string
+	}{
+		{name: "all projects", opts: SearchOptions{Scope: ScopeAllProjects}, want: []string{"a", "b"}},
+		{name: "current project", opts: SearchOptions{Scope: ScopeCurrentProject, ProjectPath: "-project-b"}, want: []string{"b"}},
+		{name: "since", opts: SearchOptions{Since: now.Add(-time.Hour)}, want: []string{"b"}},
+		{name: "until", opts: SearchOptions{Until: now.Add(-time.Hour)}, want: []string{"a"}},
+		{name: "role", opts: SearchOptions{Roles: []string{"user"}}, want: []string{"a"}},
+		{name: "min messages", opts: SearchOptions{MinMessages: 2}, want: []string{"b"}},
+		{name: "exclude", opts: SearchOptions{ExcludeUUIDs: []string{"a"}}, want: []string{"b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Query = "zeebe worker"
+
+			page, err := mock.Search(tt.opts)
+			if err != nil {
+				t.Fatalf("failed to search: %v", err)
+			}
+			matches := page.Matches
+
+			if len(matches) != len(tt.want) {
+				t.Fatalf("expected %d matches, got %d", len(tt.want), len(matches))
+			}
+			for i, uuid := range tt.want {
+				if matches[i].UUID != uuid {
+					t.Errorf("match %d: expected %q, got %q", i, uuid, matches[i].UUID)
+				}
+			}
+		})
+	}
+}
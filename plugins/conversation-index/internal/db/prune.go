@@ -0,0 +1,98 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/shared"
+)
+
+// lastPrunedSetting is the settings key holding when conversations were
+// last pruned
+const lastPrunedSetting = "last_pruned"
+
+// LastPruned returns when SetLastPruned was last called, or the zero time
+func (db *sqliteDB) LastPruned() (time.Time, error) {
+	var value string
+	err := db.conn.QueryRow(`SELECT value FROM settings WHERE key = ?`, lastPrunedSetting).Scan(&value)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read last prune time: %w", err)
+	}
+	return shared.ParseTimestamp(value)
+}
+
+// SetLastPruned records when conversations were last pruned
+func (db *sqliteDB) SetLastPruned(t time.Time) error {
+	_, err := db.conn.Exec(`INSERT OR REPLACE INTO settings (key, value) VALUES (?, ?)`, lastPrunedSetting, shared.FormatTimestamp(t))
+	if err != nil {
+		return fmt.Errorf("failed to record prune time: %w", err)
+	}
+	return nil
+}
+
+// ListConversations returns every indexed conversation, without session
+// metadata, for deciding which to remove
+func (db *sqliteDB) ListConversations() ([]Conversation, error) {
+	rows, err := db.conn.Query(`
+		SELECT uuid, project_path, encoded_path, last_updated, COALESCE(transcript_path, '')
+		FROM conversations
+		ORDER BY uuid
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list conversations: %w", err)
+	}
+	defer rows.Close()
+
+	var conversations []Conversation
+	for rows.Next() {
+		var conv Conversation
+		var lastUpdated string
+		if err := rows.Scan(&conv.UUID, &conv.ProjectPath, &conv.EncodedPath, &lastUpdated, &conv.TranscriptPath); err != nil {
+			return nil, fmt.Errorf("failed to scan conversation: %w", err)
+		}
+		conv.LastUpdated, err = shared.ParseTimestamp(lastUpdated)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse timestamp: %w", err)
+		}
+		conversations = append(conversations, conv)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return conversations, nil
+}
+
+// RemoveConversation removes a conversation and everything indexed from
+// it, including its index state, so it no longer appears in searches
+func (db *sqliteDB) RemoveConversation(uuid string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		`DELETE FROM messages WHERE conversation_uuid = ?`,
+		`DELETE FROM tool_calls WHERE conversation_uuid = ?`,
+		`DELETE FROM entries WHERE conversation_uuid = ?`,
+		`DELETE FROM summaries WHERE conversation_uuid = ?`,
+		`DELETE FROM index_state WHERE conversation_uuid = ?`,
+		`DELETE FROM conversations WHERE uuid = ?`,
+	} {
+		if _, err := tx.Exec(query, uuid); err != nil {
+			return fmt.Errorf("failed to remove conversation: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteDB_RemoveConversation(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.InitSchema(); err != nil {
+		t.Fatalf("failed to initialize schema: %v", err)
+	}
+
+	updated := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	for _, uuid := range []string{"keep", "remove"} {
+		conv := &Conversation{
+			UUID:           uuid,
+			ProjectPath:    "/Users/test/project",
+			EncodedPath:    "-Users-test-project",
+			CreatedAt:      updated,
+			LastUpdated:    updated,
+			TranscriptPath: "/tmp/" + uuid + ".jsonl",
+		}
+		if err := db.SaveConversation(conv); err != nil {
+			t.Fatalf("failed to save conversation: %v", err)
+		}
+		if err := db.SaveMessages([]Message{{ConversationUUID: uuid, Timestamp: updated, Role: "user", Content: "retention policy"}}); err != nil {
+			t.Fatalf("failed to save messages: %v", err)
+		}
+		if err := db.SaveToolCalls([]ToolCall{{ConversationUUID: uuid, Timestamp: updated, Tool: "Read", FilePath: "/src/a.go"}}); err != nil {
+			t.Fatalf("failed to save tool calls: %v", err)
+		}
+		if err := db.UpdateIndexState(&IndexState{ConversationUUID: uuid, LastIndexedLine: 1, LastModifiedTime: updated}); err != nil {
+			t.Fatalf("failed to save index state: %v", err)
+		}
+	}
+
+	conversations, err := db.ListConversations()
+	if err != nil {
+		t.Fatalf("failed to list conversations: %v", err)
+	}
+	if len(conversations) != 2 || conversations[1].TranscriptPath != "/tmp/remove.jsonl" || !conversations[1].LastUpdated.Equal(updated) {
+		t.Fatalf("expected both conversations with their paths, got %+v", conversations)
+	}
+
+	if err := db.RemoveConversation("remove"); err != nil {
+		t.Fatalf("failed to remove conversation: %v", err)
+	}
+
+	conversations, err = db.ListConversations()
+	if err != nil {
+		t.Fatalf("failed to list conversations: %v", err)
+	}
+	if len(conversations) != 1 || conversations[0].UUID != "keep" {
+		t.Errorf("expected only keep to remain, got %+v", conversations)
+	}
+
+	page, err := db.Search(SearchOptions{Query: "retention", Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if page.TotalMatches != 1 || page.Matches[0].UUID != "keep" {
+		t.Errorf("expected only keep to match, got %+v", page.Matches)
+	}
+
+	files, err := db.FilesTouched(FileOptions{Path: "/src/a.go"})
+	if err != nil {
+		t.Fatalf("failed to list files: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("expected tool calls of the removed conversation to be gone, got %+v", files)
+	}
+
+	state, err := db.GetIndexState("remove")
+	if err != nil {
+		t.Fatalf("failed to get index state: %v", err)
+	}
+	if state != nil {
+		t.Errorf("expected index state to be removed, got %+v", state)
+	}
+}
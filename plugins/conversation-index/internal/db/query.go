@@ -0,0 +1,418 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/shared"
+)
+
+// QueryTerm is a single search word or phrase
+type QueryTerm struct {
+	Text   string
+	Prefix bool // Trailing * in the query: match words starting with Text
+}
+
+// ParsedQuery is a search query broken into free text and field filters.
+//
+// The query language is deliberately small so that ordinary input never
+// produces an FTS5 syntax error:
+//
+//	zeebe worker           both terms (implicit AND)
+//	zeebe OR worker        either term
+//	"exact phrase"         phrase
+//	zeeb*                  prefix match
+//	-rabbitmq              exclude a term (also NOT rabbitmq)
+//	project:marketplace    project path contains text
+//	branch:feature/x       conversations on this git branch
+//	model:opus             conversations with messages from a matching model
+//	role:user              only messages with this role
+//	tool:Bash              only tool calls of this tool
+//	file:db.go             messages mentioning this file
+//	after:2026-01-01       messages on or after a date (also 7d, 2w)
+//	before:2026-02-01      messages before a date
+//
+// Everything else, including punctuation such as claude-marketplace, foo.go
+// or C++, is treated as literal text.
+type ParsedQuery struct {
+	Terms    [][]QueryTerm // Groups of alternatives; every group must match
+	Excluded []QueryTerm
+	Projects []string
+	Branches []string
+	Models   []string
+	Roles    []string
+	Tools    []string
+	Files    []string
+	After    time.Time
+	Before   time.Time
+}
+
+// ParseQuery parses a search query. Relative dates are resolved against now.
+func ParseQuery(input string, now time.Time) (*ParsedQuery, error) {
+	q := &ParsedQuery{}
+
+	tokens, err := tokenizeQuery(input)
+	if err != nil {
+		return nil, err
+	}
+
+	orPending := false
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+
+		if tok.field != "" {
+			if tok.negated {
+				return nil, negatedField(tok.field)
+			}
+			if err := q.addField(tok.field, tok.text, now); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if !tok.quoted {
+			switch tok.text {
+			case "AND":
+				continue
+			case "OR":
+				if len(q.Terms) > 0 {
+					orPending = true
+				}
+				continue
+			case "NOT":
+				if i+1 < len(tokens) {
+					i++
+					if tokens[i].field != "" {
+						return nil, negatedField(tokens[i].field)
+					}
+					if term, ok := queryTerm(tokens[i]); ok {
+						q.Excluded = append(q.Excluded, term)
+					}
+				}
+				continue
+			}
+		}
+
+		term, ok := queryTerm(tok)
+		if !ok {
+			continue
+		}
+
+		if tok.negated {
+			q.Excluded = append(q.Excluded, term)
+			continue
+		}
+
+		if orPending {
+			last := len(q.Terms) - 1
+			q.Terms[last] = append(q.Terms[last], term)
+			orPending = false
+		} else {
+			q.Terms = append(q.Terms, []QueryTerm{term})
+		}
+	}
+
+	return q, nil
+}
+
+// negatedField reports a field filter used with - or NOT, which only
+// exclude text terms
+func negatedField(field string) error {
+	return fmt.Errorf("%s: filters can't be negated, only text terms can be excluded", field)
+}
+
+// addField records a field:value filter
+func (q *ParsedQuery) addField(field, value string, now time.Time) error {
+	if value == "" {
+		return fmt.Errorf("missing value for %s:", field)
+	}
+
+	switch field {
+	case "project":
+		q.Projects = append(q.Projects, value)
+	case "branch":
+		q.Branches = append(q.Branches, value)
+	case "model":
+		q.Models = append(q.Models, value)
+	case "role":
+		if !IsValidRole(value) {
+			return fmt.Errorf("unknown role %q (valid roles: %s)", value, strings.Join(Roles, ", "))
+		}
+		q.Roles = append(q.Roles, value)
+	case "tool":
+		q.Tools = append(q.Tools, value)
+	case "file":
+		q.Files = append(q.Files, value)
+	case "after", "before":
+		t, err := shared.ParseDate(value, now)
+		if err != nil {
+			return fmt.Errorf("invalid %s: date: %w", field, err)
+		}
+		if field == "after" {
+			q.After = t
+		} else {
+			q.Before = t
+		}
+	}
+
+	return nil
+}
+
+// HasText reports whether the query has anything for FTS5 to match
+func (q *ParsedQuery) HasText() bool {
+	return len(q.Terms) > 0 || len(q.Tools) > 0 || len(q.Files) > 0
+}
+
+// FTS compiles the text parts of the query into an FTS5 MATCH expression.
+// Every term is quoted, so only the operators generated here are ever seen
+// by FTS5, and matching is limited to the content column.
+func (q *ParsedQuery) FTS() string {
+	var groups []string
+
+	for _, group := range q.Terms {
+		var alts []string
+		for _, term := range group {
+			alts = append(alts, term.fts())
+		}
+		if len(alts) == 1 {
+			groups = append(groups, alts[0])
+		} else {
+			groups = append(groups, "("+strings.Join(alts, " OR ")+")")
+		}
+	}
+
+	for _, tool := range q.Tools {
+		groups = append(groups, QueryTerm{Text: "Tool: " + tool}.fts())
+	}
+	for _, file := range q.Files {
+		groups = append(groups, QueryTerm{Text: file}.fts())
+	}
+
+	expr := strings.Join(groups, " AND ")
+	for _, term := range q.Excluded {
+		expr += " NOT " + term.fts()
+	}
+
+	return "content : (" + expr + ")"
+}
+
+// predicates returns the SQL filters for the non-text parts of the query,
+// in the same form as searchFilters
+func (q *ParsedQuery) predicates() ([]string, []interface{}) {
+	var clauses []string
+	var args []interface{}
+
+	if len(q.Projects) > 0 {
+		var ors []string
+		for _, project := range q.Projects {
+			ors = append(ors, `r.project_path LIKE ? ESCAPE '\'`)
+			args = append(args, "%"+escapeLike(project)+"%")
+		}
+		clauses = append(clauses, "("+strings.Join(ors, " OR ")+")")
+	}
+
+	if len(q.Branches) > 0 {
+		clauses = append(clauses, "r.git_branch IN ("+placeholders(len(q.Branches))+")")
+		for _, branch := range q.Branches {
+			args = append(args, branch)
+		}
+	}
+
+	if len(q.Models) > 0 {
+		clauses = append(clauses, modelFilter(len(q.Models)))
+		for _, model := range q.Models {
+			args = append(args, "%"+escapeLike(model)+"%")
+		}
+	}
+
+	if len(q.Roles) > 0 {
+		clauses = append(clauses, "m.role IN ("+placeholders(len(q.Roles))+")")
+		for _, role := range q.Roles {
+			args = append(args, role)
+		}
+	}
+
+	// Tool messages are matched to their calls by line; the FTS5 terms for
+	// tools and files pick the call's own message out of those on the line
+	for _, tool := range q.Tools {
+		clauses = append(clauses, "m.role = 'tool' AND "+toolCallExists("t.tool = ?"))
+		args = append(args, tool)
+	}
+	for _, file := range q.Files {
+		clauses = append(clauses, "m.role = 'tool' AND "+toolCallExists(`t.file_path LIKE ? ESCAPE '\'`))
+		args = append(args, "%"+escapeLike(file)+"%")
+	}
+
+	if !q.After.IsZero() {
+		clauses = append(clauses, "julianday(m.timestamp) >= julianday(?)")
+		args = append(args, shared.FormatTimestamp(q.After.UTC()))
+	}
+	if !q.Before.IsZero() {
+		clauses = append(clauses, "julianday(m.timestamp) < julianday(?)")
+		args = append(args, shared.FormatTimestamp(q.Before.UTC()))
+	}
+
+	return clauses, args
+}
+
+// toolCallExists returns a predicate for messages on the same transcript line
+// as a tool call matching cond, which refers to the call as t
+func toolCallExists(cond string) string {
+	return `EXISTS (
+				SELECT 1 FROM tool_calls t
+				WHERE t.conversation_uuid = m.conversation_uuid AND t.line = m.line AND ` + cond + `
+			)`
+}
+
+// fts renders a term as a quoted FTS5 string
+func (t QueryTerm) fts() string {
+	s := `"` + strings.ReplaceAll(t.Text, `"`, `""`) + `"`
+	if t.Prefix {
+		s += "*"
+	}
+	return s
+}
+
+// queryToken is a lexical token of a search query
+type queryToken struct {
+	text    string
+	field   string // Set for field:value tokens
+	quoted  bool
+	prefix  bool // Set for a quoted token followed by *
+	negated bool
+}
+
+// queryFields are the recognised field: prefixes
+var queryFields = map[string]bool{
+	"project": true,
+	"branch":  true,
+	"model":   true,
+	"role":    true,
+	"tool":    true,
+	"file":    true,
+	"before":  true,
+	"after":   true,
+}
+
+// tokenizeQuery splits a query on whitespace, keeping quoted phrases and
+// field values together
+func tokenizeQuery(input string) ([]queryToken, error) {
+	var tokens []queryToken
+	runes := []rune(input)
+
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		// A - only negates a word or phrase, so --force stays literal
+		var tok queryToken
+		if runes[i] == '-' && i+1 < len(runes) && negatable(runes[i+1]) {
+			tok.negated = true
+			i++
+		}
+
+		// field:value, where value may be quoted
+		start := i
+		for i < len(runes) && (unicode.IsLetter(runes[i])) {
+			i++
+		}
+		if i < len(runes) && runes[i] == ':' && queryFields[strings.ToLower(string(runes[start:i]))] {
+			tok.field = strings.ToLower(string(runes[start:i]))
+			i++
+		} else {
+			i = start
+		}
+
+		if i < len(runes) && runes[i] == '"' {
+			end := i + 1
+			for end < len(runes) && runes[end] != '"' {
+				end++
+			}
+			if end == len(runes) {
+				return nil, fmt.Errorf("unterminated quote in query")
+			}
+			tok.text = string(runes[i+1 : end])
+			tok.quoted = true
+			i = end + 1
+			// Allow a prefix marker right after the closing quote
+			if i < len(runes) && runes[i] == '*' {
+				tok.prefix = true
+				i++
+			}
+		} else {
+			end := i
+			for end < len(runes) && !unicode.IsSpace(runes[end]) {
+				end++
+			}
+			tok.text = string(runes[i:end])
+			i = end
+		}
+
+		tokens = append(tokens, tok)
+	}
+
+	return tokens, nil
+}
+
+// negatable reports whether a - before r negates what follows
+func negatable(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '"'
+}
+
+// queryTerm converts a token to a term, dropping tokens with no searchable
+// characters (FTS5 would treat them as an empty phrase that matches nothing)
+func queryTerm(tok queryToken) (QueryTerm, bool) {
+	// A * inside quotes is literal; only one after them marks a prefix
+	term := QueryTerm{Text: tok.text, Prefix: tok.prefix}
+	if !tok.quoted && strings.HasSuffix(term.Text, "*") {
+		term.Text = strings.TrimRight(term.Text, "*")
+		term.Prefix = true
+	}
+
+	for _, r := range term.Text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return term, true
+		}
+	}
+	return term, false
+}
+
+// escapeLike escapes LIKE wildcards for use with ESCAPE '\'
+func escapeLike(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "%", `\%`)
+	return strings.ReplaceAll(s, "_", `\_`)
+}
+
+// compileSearch parses opts.Query with ParseQuery, unless Raw is set, and
+// returns options carrying the compiled FTS5 expression and field filters
+func compileSearch(opts SearchOptions) (SearchOptions, error) {
+	if opts.Raw {
+		return opts, nil
+	}
+
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	q, err := ParseQuery(opts.Query, now)
+	if err != nil {
+		return opts, fmt.Errorf("invalid query: %w", err)
+	}
+	if !q.HasText() {
+		return opts, fmt.Errorf("invalid query: no search terms in %q", opts.Query)
+	}
+	if opts.Substring {
+		if err := substringQuery(q); err != nil {
+			return opts, fmt.Errorf("invalid query: %w", err)
+		}
+	}
+
+	opts.Query = q.FTS()
+	opts.parsed = q
+	return opts, nil
+}
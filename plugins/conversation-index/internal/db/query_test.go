@@ -0,0 +1,261 @@
+package db
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseQuery_FTS(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "plain terms",
+			input: "zeebe worker",
+			want:  `content : ("zeebe" AND "worker")`,
+		},
+		{
+			name:  "punctuation is literal",
+			input: "claude-marketplace foo.go C++",
+			want:  `content : ("claude-marketplace" AND "foo.go" AND "C++")`,
+		},
+		{
+			name:  "phrase and prefix",
+			input: `"exact phrase" zeeb*`,
+			want:  `content : ("exact phrase" AND "zeeb"*)`,
+		},
+		{
+			name:  "star inside quotes is literal",
+			input: `"a*" "exact phrase"*`,
+			want:  `content : ("a*" AND "exact phrase"*)`,
+		},
+		{
+			name:  "or groups",
+			input: "kafka OR rabbitmq consumer",
+			want:  `content : (("kafka" OR "rabbitmq") AND "consumer")`,
+		},
+		{
+			name:  "exclusions",
+			input: "deploy -staging NOT canary",
+			want:  `content : ("deploy" NOT "staging" NOT "canary")`,
+		},
+		{
+			name:  "negated phrase",
+			input: `deploy -"dry run"`,
+			want:  `content : ("deploy" NOT "dry run")`,
+		},
+		{
+			name:  "dashes before punctuation are literal",
+			input: "git push --force -.",
+			want:  `content : ("git" AND "push" AND "--force")`,
+		},
+		{
+			name:  "embedded quotes are escaped",
+			input: `say"hi`,
+			want:  `content : ("say""hi")`,
+		},
+		{
+			name:  "tool and file filters",
+			input: "tool:Edit file:internal/db/db.go",
+			want:  `content : ("Tool: Edit" AND "internal/db/db.go")`,
+		},
+		{
+			name:  "tokens without searchable characters are dropped",
+			input: "++ retry --",
+			want:  `content : ("retry")`,
+		},
+		{
+			name:  "lowercase operators are terms",
+			input: "this or that",
+			want:  `content : ("this" AND "or" AND "that")`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, err := ParseQuery(tt.input, time.Now())
+			if err != nil {
+				t.Fatalf("ParseQuery(%q) error: %v", tt.input, err)
+			}
+			if got := q.FTS(); got != tt.want {
+				t.Errorf("ParseQuery(%q).FTS() = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseQuery_Fields(t *testing.T) {
+	now := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	q, err := ParseQuery(`retry project:marketplace role:user tool:Bash file:"my dir/a.go" after:7d before:2026-01-09`, now)
+	if err != nil {
+		t.Fatalf("ParseQuery error: %v", err)
+	}
+
+	if !reflect.DeepEqual(q.Projects, []string{"marketplace"}) {
+		t.Errorf("Projects = %v", q.Projects)
+	}
+	if !reflect.DeepEqual(q.Roles, []string{"user"}) {
+		t.Errorf("Roles = %v", q.Roles)
+	}
+	if !reflect.DeepEqual(q.Tools, []string{"Bash"}) {
+		t.Errorf("Tools = %v", q.Tools)
+	}
+	if !reflect.DeepEqual(q.Files, []string{"my dir/a.go"}) {
+		t.Errorf("Files = %v", q.Files)
+	}
+	if !q.After.Equal(now.Add(-7 * 24 * time.Hour)) {
+		t.Errorf("After = %v", q.After)
+	}
+	if !q.Before.Equal(time.Date(2026, 1, 9, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("Before = %v", q.Before)
+	}
+	if len(q.Terms) != 1 || q.Terms[0][0].Text != "retry" {
+		t.Errorf("Terms = %v", q.Terms)
+	}
+
+	// Unknown prefixes are ordinary text
+	q, err = ParseQuery("http://example.com", now)
+	if err != nil {
+		t.Fatalf("ParseQuery error: %v", err)
+	}
+	if len(q.Terms) != 1 || q.Terms[0][0].Text != "http://example.com" {
+		t.Errorf("expected URL as a single term, got %v", q.Terms)
+	}
+}
+
+func TestParseQuery_Errors(t *testing.T) {
+	for _, input := range []string{
+		`"unterminated`,
+		"role:robot",
+		"after:someday",
+		"project:",
+		"deploy NOT role:user",
+		"deploy -branch:main",
+	} {
+		if _, err := ParseQuery(input, time.Now()); err == nil {
+			t.Errorf("ParseQuery(%q): expected error, got nil", input)
+		}
+	}
+}
+
+func TestSearch_QueryLanguage(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.InitSchema(); err != nil {
+		t.Fatalf("failed to initialize schema: %v", err)
+	}
+
+	conversations := []struct {
+		conv      Conversation
+		messages  []Message
+		toolCalls []ToolCall
+	}{
+		{
+			conv: Conversation{UUID: "marketplace", ProjectPath: "/Users/test/claude-marketplace", EncodedPath: "-Users-test-claude-marketplace"},
+			messages: []Message{
+				{Timestamp: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), Role: "user", Content: "publish claude-marketplace plugins written in C++", Line: 1},
+				{Timestamp: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), Role: "tool", Content: "Tool: Edit File: /Users/test/claude-marketplace/foo.go", Line: 2},
+				{Timestamp: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), Role: "user", Content: "Tool: Bash is slow, and so is parser.go", Line: 3},
+			},
+			toolCalls: []ToolCall{
+				{Timestamp: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC), Tool: "Edit", FilePath: "/Users/test/claude-marketplace/foo.go", Line: 2},
+			},
+		},
+		{
+			conv: Conversation{UUID: "api", ProjectPath: "/Users/test/api", EncodedPath: "-Users-test-api"},
+			messages: []Message{
+				{Timestamp: time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC), Role: "assistant", Content: "the marketplace API returns plugins", Line: 1},
+				{Timestamp: time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC), Role: "tool", Content: "Tool: Bash Command: go test ./...", Line: 2},
+			},
+			toolCalls: []ToolCall{
+				{Timestamp: time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC), Tool: "Bash", Command: "go test ./...", Line: 2},
+			},
+		},
+	}
+
+	for _, c := range conversations {
+		conv := c.conv
+		conv.CreatedAt = time.Now()
+		conv.LastUpdated = time.Now()
+		if err := db.SaveConversation(&conv); err != nil {
+			t.Fatalf("failed to save conversation: %v", err)
+		}
+		for i := range c.messages {
+			c.messages[i].ConversationUUID = conv.UUID
+		}
+		if err := db.SaveMessages(c.messages); err != nil {
+			t.Fatalf("failed to save messages: %v", err)
+		}
+		for i := range c.toolCalls {
+			c.toolCalls[i].ConversationUUID = conv.UUID
+		}
+		if err := db.SaveToolCalls(c.toolCalls); err != nil {
+			t.Fatalf("failed to save tool calls: %v", err)
+		}
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "claude-marketplace", want: []string{"marketplace"}},
+		{query: "foo.go", want: []string{"marketplace"}},
+		{query: "C++", want: []string{"marketplace"}},
+		{query: "plugins", want: []string{"api", "marketplace"}},
+		{query: "plugins -API", want: []string{"marketplace"}},
+		{query: "plugins project:api", want: []string{"api"}},
+		{query: "plugins role:assistant", want: []string{"api"}},
+		{query: "tool:Bash", want: []string{"api"}},
+		{query: "tool:Edit foo.go", want: []string{"marketplace"}},
+		{query: "tool:Bash foo.go", want: nil},
+		{query: "file:foo.go", want: []string{"marketplace"}},
+		{query: "file:parser.go", want: nil},
+		{query: "plugins after:2026-01-10", want: []string{"api"}},
+		{query: "plugins before:2026-01-10", want: []string{"marketplace"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			page, err := db.Search(SearchOptions{Query: tt.query, Scope: ScopeAllProjects})
+			if err != nil {
+				t.Fatalf("search %q failed: %v", tt.query, err)
+			}
+			matches := page.Matches
+
+			var got []string
+			for _, match := range matches {
+				got = append(got, match.UUID)
+			}
+			if len(got) > 1 && got[0] > got[1] {
+				got[0], got[1] = got[1], got[0]
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("search %q = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+
+	// Filters alone are not a search
+	if _, err := db.Search(SearchOptions{Query: "role:user", Scope: ScopeAllProjects}); err == nil {
+		t.Error("expected error for query without search terms")
+	}
+
+	// Raw mode passes FTS5 syntax through
+	page, err := db.Search(SearchOptions{Query: "NEAR(marketplace returns, 2)", Raw: true, Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("raw search failed: %v", err)
+	}
+	matches := page.Matches
+	if len(matches) != 1 || matches[0].UUID != "api" {
+		t.Errorf("expected raw NEAR query to match api, got %+v", matches)
+	}
+}
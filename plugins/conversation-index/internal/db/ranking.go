@@ -0,0 +1,86 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/shared"
+)
+
+// RankingWeights tunes how matching messages are scored and combined into a
+// conversation-level relevance score.
+//
+// Each matching message scores -bm25() over its content, multiplied by the
+// weight of its role. A conversation's hits are sorted best first and summed
+// with harmonically decreasing weights (1, 1/2, 1/3, ...), so the best hit
+// dominates while every additional hit still adds a little. The total can
+// then be decayed by the age of the conversation.
+type RankingWeights struct {
+	Content         float64            // bm25 weight of the content column
+	Roles           map[string]float64 // Score multiplier per message role, 1 if missing
+	RecencyHalfLife time.Duration      // Age at which a score halves, 0 disables decay
+}
+
+// DefaultRankingWeights favours what the user asked over tool invocations
+func DefaultRankingWeights() RankingWeights {
+	return RankingWeights{
+		Content: 1.0,
+		Roles: map[string]float64{
+			"user":        1.5,
+			"assistant":   1.0,
+			"thinking":    0.4,
+			"tool":        0.5,
+			"tool_result": 0.3,
+			"title":       3.0,
+		},
+	}
+}
+
+// messageScore returns the SQL expression scoring a single FTS hit, for use
+// in a query over idx joined to messages as m
+func messageScore(idx ftsIndex, weights RankingWeights) (string, []interface{}) {
+	var b strings.Builder
+	args := []interface{}{weights.Content}
+
+	// Only content contributes to ranking, never conversation_uuid
+	b.WriteString("-" + idx.bm25())
+
+	if len(weights.Roles) > 0 {
+		roles := make([]string, 0, len(weights.Roles))
+		for role := range weights.Roles {
+			roles = append(roles, role)
+		}
+		sort.Strings(roles)
+
+		b.WriteString(" * CASE m.role")
+		for _, role := range roles {
+			b.WriteString(" WHEN ? THEN ?")
+			args = append(args, role, weights.Roles[role])
+		}
+		b.WriteString(" ELSE 1.0 END")
+	}
+
+	return b.String(), args
+}
+
+// recencyDecay returns the SQL expression for the age decay of a conversation
+// last active at the lastActive column, or an empty string when decay is
+// disabled
+func recencyDecay(weights RankingWeights, now time.Time, lastActive string) (string, []interface{}) {
+	if weights.RecencyHalfLife <= 0 {
+		return "", nil
+	}
+
+	halfLifeDays := weights.RecencyHalfLife.Hours() / 24
+	expr := "pow(0.5, max(julianday(?) - julianday(" + lastActive + "), 0) / ?)"
+	return expr, []interface{}{shared.FormatTimestamp(now.UTC()), halfLifeDays}
+}
+
+// rankingWeights resolves the weights to use for a search
+func rankingWeights(opts SearchOptions) RankingWeights {
+	if opts.Weights != nil {
+		return *opts.Weights
+	}
+	return DefaultRankingWeights()
+}
@@ -0,0 +1,172 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// seedRankingDB creates conversations with the given messages, all in one project
+func seedRankingDB(t *testing.T, conversations map[string][]Message, updated map[string]time.Time) DB {
+	t.Helper()
+
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.InitSchema(); err != nil {
+		t.Fatalf("failed to initialize schema: %v", err)
+	}
+
+	for uuid, messages := range conversations {
+		lastUpdated, ok := updated[uuid]
+		if !ok {
+			lastUpdated = time.Now()
+		}
+		conv := &Conversation{
+			UUID:        uuid,
+			ProjectPath: "/Users/test/project",
+			EncodedPath: "-Users-test-project",
+			CreatedAt:   lastUpdated,
+			LastUpdated: lastUpdated,
+		}
+		if err := db.SaveConversation(conv); err != nil {
+			t.Fatalf("failed to save conversation %s: %v", uuid, err)
+		}
+		for i := range messages {
+			messages[i].ConversationUUID = uuid
+			messages[i].Timestamp = lastUpdated
+		}
+		if err := db.SaveMessages(messages); err != nil {
+			t.Fatalf("failed to save messages for %s: %v", uuid, err)
+		}
+	}
+
+	return db
+}
+
+func searchUUIDs(t *testing.T, db DB, opts SearchOptions) []string {
+	t.Helper()
+
+	opts.Scope = ScopeAllProjects
+	page, err := db.Search(opts)
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	matches := page.Matches
+
+	var uuids []string
+	for _, match := range matches {
+		if match.RelevanceScore <= 0 {
+			t.Errorf("expected positive relevance for %s, got %v", match.UUID, match.RelevanceScore)
+		}
+		uuids = append(uuids, match.UUID)
+	}
+	return uuids
+}
+
+func TestSearch_RanksByAggregatedHits(t *testing.T) {
+	db := seedRankingDB(t, map[string][]Message{
+		"one-hit": {
+			{Role: "assistant", Content: "the webhook retry logic lives in the worker"},
+			{Role: "assistant", Content: "unrelated discussion about lunch"},
+		},
+		"many-hits": {
+			{Role: "assistant", Content: "the webhook retry logic lives in the worker"},
+			{Role: "assistant", Content: "webhook retries back off exponentially"},
+			{Role: "assistant", Content: "we log every webhook delivery"},
+		},
+	}, nil)
+
+	got := searchUUIDs(t, db, SearchOptions{Query: "webhook"})
+	if len(got) != 2 || got[0] != "many-hits" {
+		t.Errorf("expected many-hits ranked first, got %v", got)
+	}
+
+	page, err := db.Search(SearchOptions{Query: "webhook", Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	matches := page.Matches
+	if matches[0].Hits != 3 || matches[1].Hits != 1 {
+		t.Errorf("expected 3 and 1 hits, got %d and %d", matches[0].Hits, matches[1].Hits)
+	}
+	if matches[0].RelevanceScore <= matches[1].RelevanceScore {
+		t.Errorf("expected descending relevance, got %v then %v", matches[0].RelevanceScore, matches[1].RelevanceScore)
+	}
+}
+
+func TestSearch_RoleWeights(t *testing.T) {
+	db := seedRankingDB(t, map[string][]Message{
+		"asked": {
+			{Role: "user", Content: "how do feature flags work here"},
+		},
+		"tooled": {
+			{Role: "tool", Content: "Tool: Grep Pattern: feature flags"},
+		},
+	}, nil)
+
+	got := searchUUIDs(t, db, SearchOptions{Query: "feature flags"})
+	if len(got) != 2 || got[0] != "asked" {
+		t.Errorf("expected user message ranked first by default, got %v", got)
+	}
+
+	weights := DefaultRankingWeights()
+	weights.Roles["user"] = 0.1
+	weights.Roles["tool"] = 10
+	got = searchUUIDs(t, db, SearchOptions{Query: "feature flags", Weights: &weights})
+	if len(got) != 2 || got[0] != "tooled" {
+		t.Errorf("expected tool message ranked first with boosted tool weight, got %v", got)
+	}
+}
+
+func TestSearch_RecencyDecay(t *testing.T) {
+	now := time.Now()
+	db := seedRankingDB(t, map[string][]Message{
+		"old": {
+			{Role: "user", Content: "rotate the signing keys"},
+			{Role: "user", Content: "signing keys rotated"},
+		},
+		"recent": {
+			{Role: "user", Content: "rotate the signing keys"},
+		},
+	}, map[string]time.Time{
+		"old":    now.AddDate(-1, 0, 0),
+		"recent": now,
+	})
+
+	got := searchUUIDs(t, db, SearchOptions{Query: "signing keys"})
+	if len(got) != 2 || got[0] != "old" {
+		t.Errorf("expected old conversation first without decay, got %v", got)
+	}
+
+	weights := DefaultRankingWeights()
+	weights.RecencyHalfLife = 30 * 24 * time.Hour
+	got = searchUUIDs(t, db, SearchOptions{Query: "signing keys", Weights: &weights})
+	if len(got) != 2 || got[0] != "recent" {
+		t.Errorf("expected recent conversation first with decay, got %v", got)
+	}
+}
+
+func TestSearch_ThinkingWeight(t *testing.T) {
+	db := seedRankingDB(t, map[string][]Message{
+		"answered": {
+			{Role: "assistant", Content: "the cache eviction runs hourly"},
+		},
+		"pondered": {
+			{Role: "thinking", Content: "maybe the cache eviction runs hourly"},
+		},
+	}, nil)
+
+	got := searchUUIDs(t, db, SearchOptions{Query: "cache eviction"})
+	if len(got) != 2 || got[0] != "answered" {
+		t.Errorf("expected the answer ranked above thinking, got %v", got)
+	}
+
+	got = searchUUIDs(t, db, SearchOptions{Query: "cache eviction role:thinking"})
+	if len(got) != 1 || got[0] != "pondered" {
+		t.Errorf("expected only thinking with role:thinking, got %v", got)
+	}
+}
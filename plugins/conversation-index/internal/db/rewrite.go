@@ -0,0 +1,99 @@
+package db
+
+import "fmt"
+
+// rewriteBatchSize is how many rows RewriteContent reads and updates at a time
+const rewriteBatchSize = 500
+
+// rewriteColumns are the stored columns that hold transcript text
+var rewriteColumns = []struct {
+	table, key, column string
+}{
+	{"messages", "id", "content"},
+	{"conversations", "uuid", "title"},
+	{"tool_calls", "id", "command"},
+	{"tool_calls", "id", "pattern"},
+	{"tool_calls", "id", "prompt"},
+}
+
+// RewriteContent passes the stored text of messages, titles and tool calls
+// through rewrite, saving any that change, and returns how many values
+// changed. The search indexes are updated by their triggers, though the old
+// text stays in the database file until it is optimized and vacuumed.
+func (db *sqliteDB) RewriteContent(rewrite func(string) string) (int, error) {
+	changed := 0
+	for _, c := range rewriteColumns {
+		n, err := db.rewriteColumn(c.table, c.key, c.column, rewrite)
+		changed += n
+		if err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// rewriteColumn rewrites one column in batches ordered by key
+func (db *sqliteDB) rewriteColumn(table, key, column string, rewrite func(string) string) (int, error) {
+	selectQuery := `SELECT ` + key + `, ` + column + ` FROM ` + table + `
+		WHERE ` + key + ` > ? AND ` + column + ` IS NOT NULL
+		ORDER BY ` + key + ` LIMIT ?`
+	updateQuery := `UPDATE ` + table + ` SET ` + column + ` = ? WHERE ` + key + ` = ?`
+
+	changed := 0
+	var after interface{} = ""
+	if key == "id" {
+		after = 0
+	}
+	for {
+		rows, err := db.conn.Query(selectQuery, after, rewriteBatchSize)
+		if err != nil {
+			return changed, fmt.Errorf("failed to read %s.%s: %w", table, column, err)
+		}
+
+		type update struct {
+			key   interface{}
+			value string
+		}
+		var updates []update
+		n := 0
+		for rows.Next() {
+			var k interface{}
+			var value string
+			if err := rows.Scan(&k, &value); err != nil {
+				rows.Close()
+				return changed, fmt.Errorf("failed to scan %s.%s: %w", table, column, err)
+			}
+			n++
+			after = k
+			if rewritten := rewrite(value); rewritten != value {
+				updates = append(updates, update{k, rewritten})
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return changed, fmt.Errorf("error iterating rows: %w", err)
+		}
+
+		if len(updates) > 0 {
+			tx, err := db.conn.Begin()
+			if err != nil {
+				return changed, fmt.Errorf("failed to begin transaction: %w", err)
+			}
+			for _, u := range updates {
+				if _, err := tx.Exec(updateQuery, u.value, u.key); err != nil {
+					tx.Rollback()
+					return changed, fmt.Errorf("failed to update %s.%s: %w", table, column, err)
+				}
+			}
+			if err := tx.Commit(); err != nil {
+				return changed, fmt.Errorf("failed to commit transaction: %w", err)
+			}
+			changed += len(updates)
+		}
+
+		if n < rewriteBatchSize {
+			return changed, nil
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQLiteDB_RewriteContent(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.InitSchema(); err != nil {
+		t.Fatalf("failed to initialize schema: %v", err)
+	}
+
+	now := time.Now()
+	conv := &Conversation{
+		UUID:        "conv-1",
+		ProjectPath: "/Users/test/project",
+		EncodedPath: "-Users-test-project",
+		CreatedAt:   now,
+		LastUpdated: now,
+	}
+	if err := db.SaveConversation(conv); err != nil {
+		t.Fatalf("failed to save conversation: %v", err)
+	}
+
+	// More messages than one batch
+	var messages []Message
+	for i := 0; i < rewriteBatchSize+10; i++ {
+		messages = append(messages, Message{ConversationUUID: "conv-1", Timestamp: now, Role: "user", Content: fmt.Sprintf("message %d", i)})
+	}
+	messages = append(messages, Message{ConversationUUID: "conv-1", Timestamp: now, Role: "tool", Content: "Tool: Bash Command: login hunter22"})
+	if err := db.SaveMessages(messages); err != nil {
+		t.Fatalf("failed to save messages: %v", err)
+	}
+	if err := db.SetTitle(Message{ConversationUUID: "conv-1", Timestamp: now, Content: "Rotate hunter22"}); err != nil {
+		t.Fatalf("failed to set title: %v", err)
+	}
+	if err := db.SaveToolCalls([]ToolCall{{ConversationUUID: "conv-1", Timestamp: now, Tool: "Bash", Command: "login hunter22"}}); err != nil {
+		t.Fatalf("failed to save tool calls: %v", err)
+	}
+
+	changed, err := db.RewriteContent(func(s string) string {
+		return strings.ReplaceAll(s, "hunter22", "[REDACTED:secret]")
+	})
+	if err != nil {
+		t.Fatalf("failed to rewrite content: %v", err)
+	}
+
+	// The tool message, the title and its message, and the command
+	if changed != 4 {
+		t.Errorf("expected 4 values changed, got %d", changed)
+	}
+
+	for _, opts := range []SearchOptions{{Query: "hunter22"}, {Query: "hunter22", Substring: true}} {
+		opts.Scope = ScopeAllProjects
+		page, err := db.SearchMessages(opts)
+		if err != nil {
+			t.Fatalf("failed to search: %v", err)
+		}
+		if page.TotalMatches != 0 {
+			t.Errorf("expected the old text to be gone from the index, got %+v", page.Messages)
+		}
+	}
+
+	page, err := db.Search(SearchOptions{Query: "redacted", Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if page.TotalMatches != 1 || page.Matches[0].Title != "Rotate [REDACTED:secret]" {
+		t.Errorf("expected the redacted title, got %+v", page.Matches)
+	}
+
+	var command string
+	if err := db.(*sqliteDB).conn.QueryRow(`SELECT command FROM tool_calls`).Scan(&command); err != nil {
+		t.Fatalf("failed to read tool call: %v", err)
+	}
+	if command != "login [REDACTED:secret]" {
+		t.Errorf("expected the redacted command, got %q", command)
+	}
+
+	// Nothing changes the second time
+	changed, err = db.RewriteContent(func(s string) string {
+		return strings.ReplaceAll(s, "hunter22", "[REDACTED:secret]")
+	})
+	if err != nil || changed != 0 {
+		t.Errorf("expected no changes, got %d, %v", changed, err)
+	}
+}
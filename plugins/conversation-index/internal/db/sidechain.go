@@ -0,0 +1,35 @@
+package db
+
+import "fmt"
+
+// LinkSidechains records the subagent_type of sidechain conversations that
+// don't have one yet. A subagent's sidechain starts with the prompt of the
+// Task call that launched it, so the call is found by matching the prompt
+// among its parent's tool calls. Sidechains whose parent isn't indexed yet
+// are linked on a later run.
+func (db *sqliteDB) LinkSidechains() error {
+	query := `
+		UPDATE conversations
+		SET subagent_type = (
+			SELECT t.subagent_type
+			FROM tool_calls t
+			WHERE t.conversation_uuid = conversations.parent_uuid
+				AND t.subagent_type IS NOT NULL
+				AND t.prompt = (
+					SELECT m.content FROM messages m
+					WHERE m.conversation_uuid = conversations.uuid AND m.role = 'user'
+					ORDER BY m.id
+					LIMIT 1
+				)
+			ORDER BY t.id
+			LIMIT 1
+		)
+		WHERE parent_uuid IS NOT NULL AND subagent_type IS NULL
+	`
+
+	if _, err := db.conn.Exec(query); err != nil {
+		return fmt.Errorf("failed to link sidechains: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,143 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteDB_Sidechains(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.InitSchema(); err != nil {
+		t.Fatalf("failed to initialize schema: %v", err)
+	}
+
+	// The subagent is indexed before the session that started it
+	now := time.Now()
+	save := func(conv *Conversation, messages []Message, calls []ToolCall) {
+		t.Helper()
+		conv.ProjectPath = "/Users/test/project"
+		conv.EncodedPath = "-Users-test-project"
+		conv.CreatedAt = now
+		conv.LastUpdated = now
+		if err := db.SaveConversation(conv); err != nil {
+			t.Fatalf("failed to save conversation: %v", err)
+		}
+		for i := range messages {
+			messages[i].ConversationUUID = conv.UUID
+			messages[i].Timestamp = now
+			messages[i].Sidechain = conv.ParentUUID != ""
+		}
+		if err := db.SaveMessages(messages); err != nil {
+			t.Fatalf("failed to save messages: %v", err)
+		}
+		for i := range calls {
+			calls[i].ConversationUUID = conv.UUID
+			calls[i].Timestamp = now
+		}
+		if err := db.SaveToolCalls(calls); err != nil {
+			t.Fatalf("failed to save tool calls: %v", err)
+		}
+	}
+
+	save(&Conversation{UUID: "agent-1", ParentUUID: "session"}, []Message{
+		{Role: "user", Content: "Find where retries are configured"},
+		{Role: "assistant", Content: "retries live in the webhook worker"},
+		{Role: "assistant", Content: "the backoff is jittered"},
+	}, nil)
+	if err := db.LinkSidechains(); err != nil {
+		t.Fatalf("failed to link sidechains: %v", err)
+	}
+
+	save(&Conversation{UUID: "session"}, []Message{
+		{Role: "user", Content: "why do webhook deliveries repeat"},
+		{Role: "tool", Content: "Tool: Task Find where retries are configured"},
+	}, []ToolCall{
+		{Tool: "Task", SubagentType: "Explore", Prompt: "Find where retries are configured"},
+	})
+	if err := db.LinkSidechains(); err != nil {
+		t.Fatalf("failed to link sidechains: %v", err)
+	}
+
+	search := func(query string, hide bool) []Match {
+		t.Helper()
+		page, err := db.Search(SearchOptions{Query: query, Scope: ScopeAllProjects, HideSidechainOnly: hide})
+		if err != nil {
+			t.Fatalf("failed to search: %v", err)
+		}
+		return page.Matches
+	}
+
+	// Hits in the sidechain roll up to the session
+	matches := search("webhook", false)
+	if len(matches) != 1 || matches[0].UUID != "session" {
+		t.Fatalf("expected only the session to match, got %+v", matches)
+	}
+	if matches[0].Hits != 2 || matches[0].SidechainHits != 1 {
+		t.Errorf("expected 2 hits with 1 in the sidechain, got %d and %d", matches[0].Hits, matches[0].SidechainHits)
+	}
+
+	var subagent *Excerpt
+	for i, excerpt := range matches[0].Excerpts {
+		if excerpt.Sidechain {
+			subagent = &matches[0].Excerpts[i]
+		}
+	}
+	if subagent == nil || subagent.Subagent != "Explore" {
+		t.Errorf("expected an excerpt from the Explore subagent, got %+v", matches[0].Excerpts)
+	}
+
+	// A conversation matched only through its subagent can be hidden
+	if got := search("jittered", false); len(got) != 1 || got[0].SidechainHits != 1 {
+		t.Errorf("expected the session to match through its subagent, got %+v", got)
+	}
+	if got := search("jittered", true); len(got) != 0 {
+		t.Errorf("expected sidechain-only matches to be hidden, got %+v", got)
+	}
+
+	// Conversation filters apply to the session a sidechain rolls up to, so
+	// an excluded session doesn't come back through its subagent
+	for _, opts := range []SearchOptions{
+		{Query: "jittered", Scope: ScopeAllProjects, ExcludeUUIDs: []string{"session"}},
+		{Query: "jittered", Scope: ScopeAllProjects, MinMessages: 3}, // The session has 2, agent-1 has 3
+	} {
+		page, err := db.Search(opts)
+		if err != nil {
+			t.Fatalf("failed to search: %v", err)
+		}
+		if len(page.Matches) != 0 {
+			t.Errorf("expected no matches for %+v, got %+v", opts, page.Matches)
+		}
+		messages, err := db.SearchMessages(opts)
+		if err != nil {
+			t.Fatalf("failed to search messages: %v", err)
+		}
+		if len(messages.Messages) != 0 {
+			t.Errorf("expected no messages for %+v, got %+v", opts, messages.Messages)
+		}
+	}
+
+	page, err := db.SearchMessages(SearchOptions{Query: "jittered", Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to search messages: %v", err)
+	}
+	if len(page.Messages) != 1 || page.Messages[0].ConversationUUID != "agent-1" || page.Messages[0].ParentUUID != "session" {
+		t.Errorf("expected the sidechain message with its parent, got %+v", page.Messages)
+	}
+
+	// Re-saving the sidechain without a parent keeps the link
+	save(&Conversation{UUID: "agent-1"}, nil, nil)
+	var parent, subagentType string
+	err = db.(*sqliteDB).conn.QueryRow(`SELECT parent_uuid, subagent_type FROM conversations WHERE uuid = 'agent-1'`).Scan(&parent, &subagentType)
+	if err != nil {
+		t.Fatalf("failed to read conversation: %v", err)
+	}
+	if parent != "session" || subagentType != "Explore" {
+		t.Errorf("expected agent-1 linked to session as Explore, got %q and %q", parent, subagentType)
+	}
+}
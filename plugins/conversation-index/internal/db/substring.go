@@ -0,0 +1,81 @@
+package db
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// minSubstringLength is the shortest term the trigram tokenizer can match
+const minSubstringLength = 3
+
+// ftsIndex is an FTS5 table over messages that searches can run against
+type ftsIndex struct {
+	table         string
+	contentColumn int
+	columns       int
+}
+
+var (
+	// wordIndex is messages_fts, tokenized into whole words
+	wordIndex = ftsIndex{table: "messages_fts", contentColumn: 1, columns: 2}
+
+	// trigramIndex is messages_trigram, which matches any substring of
+	// three or more characters
+	trigramIndex = ftsIndex{table: "messages_trigram", contentColumn: 0, columns: 1}
+)
+
+// searchIndex returns the FTS5 table to search for opts
+func searchIndex(opts SearchOptions) ftsIndex {
+	if opts.Substring {
+		return trigramIndex
+	}
+	return wordIndex
+}
+
+// bm25 returns a bm25() call that only ranks on the content column,
+// taking the content weight as its single argument
+func (idx ftsIndex) bm25() string {
+	expr := "bm25(" + idx.table
+	for i := 0; i < idx.columns; i++ {
+		if i == idx.contentColumn {
+			expr += ", ?"
+		} else {
+			expr += ", 0.0"
+		}
+	}
+	return expr + ")"
+}
+
+// substringQuery adapts a parsed query to the trigram index: every term is
+// already a substring match, so prefix markers are dropped, and terms too
+// short to form a trigram are rejected rather than silently matching nothing
+func substringQuery(q *ParsedQuery) error {
+	check := func(text string) error {
+		if utf8.RuneCountInString(text) < minSubstringLength {
+			return fmt.Errorf("substring terms need at least %d characters: %q", minSubstringLength, text)
+		}
+		return nil
+	}
+
+	for _, group := range q.Terms {
+		for i := range group {
+			group[i].Prefix = false
+			if err := check(group[i].Text); err != nil {
+				return err
+			}
+		}
+	}
+	for i := range q.Excluded {
+		q.Excluded[i].Prefix = false
+		if err := check(q.Excluded[i].Text); err != nil {
+			return err
+		}
+	}
+	for _, file := range q.Files {
+		if err := check(file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSQLiteDB_SubstringSearch(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.InitSchema(); err != nil {
+		t.Fatalf("failed to initialize schema: %v", err)
+	}
+
+	conv := &Conversation{
+		UUID:        "conv-1",
+		ProjectPath: "/Users/test/project",
+		EncodedPath: "-Users-test-project",
+		CreatedAt:   time.Now(),
+		LastUpdated: time.Now(),
+	}
+	if err := db.SaveConversation(conv); err != nil {
+		t.Fatalf("failed to save conversation: %v", err)
+	}
+	messages := []Message{
+		{ConversationUUID: "conv-1", Timestamp: time.Now(), Role: "assistant", Content: "The bug is in indexConversation, called from IndexAll"},
+		{ConversationUUID: "conv-1", Timestamp: time.Now(), Role: "tool", Content: "panic at /home/dev/plugins/conversation-index/internal/db/db.go:214"},
+	}
+	if err := db.SaveMessages(messages); err != nil {
+		t.Fatalf("failed to save messages: %v", err)
+	}
+
+	// Word search can't see inside identifiers
+	page, err := db.Search(SearchOptions{Query: "IndexConv", Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("word search failed: %v", err)
+	}
+	matches := page.Matches
+	if len(matches) != 0 {
+		t.Errorf("expected no word matches for a partial identifier, got %d", len(matches))
+	}
+
+	for _, query := range []string{"IndexConv", "ternal/db/db.go:21", "versation -nomatch"} {
+		page, err := db.Search(SearchOptions{Query: query, Substring: true, Scope: ScopeAllProjects})
+		if err != nil {
+			t.Fatalf("substring search %q failed: %v", query, err)
+		}
+		matches := page.Matches
+		if len(matches) != 1 {
+			t.Fatalf("substring search %q: expected 1 match, got %d", query, len(matches))
+		}
+		if len(matches[0].Excerpts) == 0 || len(matches[0].Excerpts[0].Highlights) == 0 {
+			t.Errorf("substring search %q: expected a highlighted excerpt, got %+v", query, matches[0].Excerpts)
+		}
+	}
+
+	messagePage, err := db.SearchMessages(SearchOptions{Query: "IndexConv", Substring: true, Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("substring message search failed: %v", err)
+	}
+	results := messagePage.Messages
+	if len(results) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(results))
+	}
+	h := results[0].Highlights[0]
+	if got := results[0].Text[h.Start:h.End]; !strings.EqualFold(got, "indexconv") {
+		t.Errorf("expected highlight on the matched substring, got %q", got)
+	}
+
+	if _, err := db.Search(SearchOptions{Query: "db", Substring: true, Scope: ScopeAllProjects}); err == nil {
+		t.Error("expected error for a substring term shorter than a trigram")
+	}
+
+	// Deleting a conversation must also clear the trigram index
+	if err := db.DeleteConversation("conv-1"); err != nil {
+		t.Fatalf("failed to delete conversation: %v", err)
+	}
+	page, err = db.Search(SearchOptions{Query: "IndexConv", Substring: true, Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("substring search failed: %v", err)
+	}
+	matches = page.Matches
+	if len(matches) != 0 {
+		t.Errorf("expected no matches after delete, got %d", len(matches))
+	}
+}
@@ -0,0 +1,197 @@
+package db
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxSuggestions caps the suggestions returned for a search without matches
+const maxSuggestions = 5
+
+// Suggestion is a likely correction for a search term that matched nothing
+type Suggestion struct {
+	Term        string `json:"term"`        // Search term with no matches
+	Replacement string `json:"replacement"` // Indexed term close to Term
+	Documents   int    `json:"documents"`   // Messages containing Replacement
+	Query       string `json:"query"`       // The search query with Term replaced
+}
+
+// suggestions returns corrections for a compiled word search without
+// matches. Raw and substring searches don't get suggestions, and neither do
+// searches of a porter index, whose vocabulary holds stems like "retri"
+// rather than words to suggest.
+func (db *sqliteDB) suggestions(input string, opts SearchOptions) ([]Suggestion, error) {
+	if opts.parsed == nil || opts.Substring {
+		return nil, nil
+	}
+	spec, err := db.Tokenizer()
+	if err != nil {
+		return nil, err
+	}
+	if stemmed(spec) {
+		return nil, nil
+	}
+	return db.suggest(input, opts.parsed)
+}
+
+// stemmed reports whether a tokenize option uses the porter stemmer
+func stemmed(spec string) bool {
+	for _, word := range strings.Fields(spec) {
+		if word == "porter" {
+			return true
+		}
+	}
+	return false
+}
+
+// suggest returns corrections for the terms of a parsed query that don't
+// occur in the index, drawn from the messages_vocab vocabulary and ranked by
+// edit distance and then by how many messages contain them
+func (db *sqliteDB) suggest(input string, q *ParsedQuery) ([]Suggestion, error) {
+	var unknown []string
+	for _, group := range q.Terms {
+		for _, term := range group {
+			if term.Prefix || !isWord(term.Text) || utf8.RuneCountInString(term.Text) < 3 {
+				continue
+			}
+
+			var n int
+			match := "content : " + term.fts()
+			if err := db.conn.QueryRow(`SELECT COUNT(*) FROM messages_fts WHERE messages_fts MATCH ?`, match).Scan(&n); err != nil {
+				return nil, fmt.Errorf("failed to count term: %w", err)
+			}
+			if n == 0 {
+				unknown = append(unknown, term.Text)
+			}
+		}
+	}
+	if len(unknown) == 0 {
+		return nil, nil
+	}
+
+	minLen, maxLen := 1<<30, 0
+	for _, term := range unknown {
+		n := utf8.RuneCountInString(term)
+		minLen = min(minLen, n-maxEdits(n))
+		maxLen = max(maxLen, n+maxEdits(n))
+	}
+
+	rows, err := db.conn.Query(`
+		SELECT term, doc FROM messages_vocab
+		WHERE col = 'content' AND length(term) BETWEEN ? AND ?
+	`, minLen, maxLen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vocabulary: %w", err)
+	}
+	defer rows.Close()
+
+	type candidate struct {
+		Suggestion
+		distance int
+	}
+	var candidates []candidate
+	for rows.Next() {
+		var vocab string
+		var docs int
+		if err := rows.Scan(&vocab, &docs); err != nil {
+			return nil, fmt.Errorf("failed to scan vocabulary: %w", err)
+		}
+
+		for _, term := range unknown {
+			lower := strings.ToLower(term)
+			d := editDistance(lower, vocab)
+			if d == 0 || d > maxEdits(utf8.RuneCountInString(term)) {
+				continue
+			}
+			candidates = append(candidates, candidate{
+				Suggestion: Suggestion{
+					Term:        term,
+					Replacement: vocab,
+					Documents:   docs,
+					Query:       replaceTerm(input, term, vocab),
+				},
+				distance: d,
+			})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating vocabulary: %w", err)
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		if a.Documents != b.Documents {
+			return a.Documents > b.Documents
+		}
+		return a.Replacement < b.Replacement
+	})
+
+	var suggestions []Suggestion
+	for _, c := range candidates {
+		if len(suggestions) == maxSuggestions {
+			break
+		}
+		suggestions = append(suggestions, c.Suggestion)
+	}
+	return suggestions, nil
+}
+
+// maxEdits is how many edits a term of n characters may be from a suggestion
+func maxEdits(n int) int {
+	if n <= 4 {
+		return 1
+	}
+	return 2
+}
+
+// isWord reports whether s is a single run of letters and digits
+func isWord(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// replaceTerm replaces the first whole-word, case-insensitive occurrence of
+// term in query
+func replaceTerm(query, term, replacement string) string {
+	re := regexp.MustCompile(`(?i)(^|[^\pL\pN])` + regexp.QuoteMeta(term) + `($|[^\pL\pN])`)
+	loc := re.FindStringSubmatchIndex(query)
+	if loc == nil {
+		return query
+	}
+	return query[:loc[3]] + replacement + query[loc[4]:]
+}
+
+// editDistance returns the Levenshtein distance between a and b in runes
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
@@ -0,0 +1,132 @@
+package db
+
+import (
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"webhook", "webhook", 0},
+		{"webhok", "webhook", 1},
+		{"wbehook", "webhook", 2},
+		{"kafka", "kafak", 2},
+		{"", "abc", 3},
+		{"café", "cafe", 1},
+	}
+
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestReplaceTerm(t *testing.T) {
+	tests := []struct {
+		query, term, replacement, want string
+	}{
+		{"webhok retries", "webhok", "webhook", "webhook retries"},
+		{"retries Webhok", "webhok", "webhook", "retries webhook"},
+		{"webhoks webhok", "webhok", "webhook", "webhoks webhook"},
+		{`"the webhok" -foo`, "webhok", "webhook", `"the webhook" -foo`},
+		{"project:api webhok", "webhok", "webhook", "project:api webhook"},
+	}
+
+	for _, tt := range tests {
+		if got := replaceTerm(tt.query, tt.term, tt.replacement); got != tt.want {
+			t.Errorf("replaceTerm(%q, %q) = %q, want %q", tt.query, tt.term, got, tt.want)
+		}
+	}
+}
+
+func TestSearch_Suggestions(t *testing.T) {
+	db := seedRankingDB(t, map[string][]Message{
+		"conv-a": {
+			{Role: "user", Content: "the webhook retries failed deliveries"},
+			{Role: "assistant", Content: "webhook signatures are verified"},
+		},
+		"conv-b": {
+			{Role: "user", Content: "register webhooks for the webhook service"},
+			{Role: "tool", Content: "Tool: Bash Command: curl webhoot.example.com"},
+			{Role: "assistant", Content: "both webhooks now retry"},
+		},
+	}, nil)
+
+	page, err := db.Search(SearchOptions{Query: "webhok retries", Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if page.TotalMatches != 0 {
+		t.Fatalf("expected no matches, got %d", page.TotalMatches)
+	}
+	if len(page.Suggestions) == 0 {
+		t.Fatal("expected suggestions")
+	}
+
+	best := page.Suggestions[0]
+	if best.Term != "webhok" || best.Replacement != "webhook" || best.Query != "webhook retries" || best.Documents != 3 {
+		t.Errorf("unexpected best suggestion: %+v", best)
+	}
+
+	// Equally close terms are ordered by document frequency
+	var replacements []string
+	for _, s := range page.Suggestions {
+		replacements = append(replacements, s.Replacement)
+	}
+	if len(replacements) < 3 || replacements[1] != "webhooks" || replacements[2] != "webhoot" {
+		t.Errorf("unexpected suggestion order: %v", replacements)
+	}
+
+	// The suggested query finds the conversation
+	page, err = db.Search(SearchOptions{Query: best.Query, Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to search suggestion: %v", err)
+	}
+	if page.TotalMatches != 1 || len(page.Suggestions) != 0 {
+		t.Errorf("expected suggested query to match without suggestions, got %+v", page.PageInfo)
+	}
+
+	messages, err := db.SearchMessages(SearchOptions{Query: "webhok", Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to search messages: %v", err)
+	}
+	if len(messages.Suggestions) == 0 || messages.Suggestions[0].Replacement != "webhook" {
+		t.Errorf("expected message search suggestions, got %+v", messages.Suggestions)
+	}
+
+	// Known terms filtered out of the results aren't misspellings
+	page, err = db.Search(SearchOptions{Query: "signatures role:user", Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if page.TotalMatches != 0 || len(page.Suggestions) != 0 {
+		t.Errorf("expected no matches and no suggestions, got %d matches, %+v", page.TotalMatches, page.Suggestions)
+	}
+
+	// The vocabulary follows a tokenizer rebuild
+	if _, err := db.SetTokenizer(Tokenizer{RemoveDiacritics: 2, TokenChars: "_"}); err != nil {
+		t.Fatalf("failed to set tokenizer: %v", err)
+	}
+	page, err = db.Search(SearchOptions{Query: "webhok", Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to search after rebuild: %v", err)
+	}
+	if len(page.Suggestions) == 0 || page.Suggestions[0].Replacement != "webhook" {
+		t.Errorf("expected suggestions after rebuild, got %+v", page.Suggestions)
+	}
+
+	// A porter vocabulary holds stems, which aren't suggested
+	if _, err := db.SetTokenizer(Tokenizer{Porter: true, RemoveDiacritics: 1}); err != nil {
+		t.Fatalf("failed to set tokenizer: %v", err)
+	}
+	page, err = db.Search(SearchOptions{Query: "webhok", Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to search with porter: %v", err)
+	}
+	if page.TotalMatches != 0 || len(page.Suggestions) != 0 {
+		t.Errorf("expected no matches and no suggestions with porter, got %d matches, %+v", page.TotalMatches, page.Suggestions)
+	}
+}
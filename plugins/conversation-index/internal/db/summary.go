@@ -0,0 +1,107 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/shared"
+)
+
+// saveSummaries records the summary entries of an update and retitles the
+// conversations holding their leaves. A summary whose leaf isn't indexed yet
+// is kept, and titles its conversation when that conversation's entries are
+// saved. The latest summary recorded for a conversation's entries wins.
+func saveSummaries(q execer, u ConversationUpdate) error {
+	uuid := u.Conversation.UUID
+
+	var leaves []string
+	for _, s := range u.Summaries {
+		if s.LeafUUID == "" {
+			if err := setTitle(q, s.Title); err != nil {
+				return err
+			}
+			continue
+		}
+		_, err := q.Exec(`
+			INSERT OR REPLACE INTO summaries (leaf_uuid, conversation_uuid, line, timestamp, title)
+			VALUES (?, ?, NULLIF(?, 0), ?, ?)
+		`, s.LeafUUID, s.Title.ConversationUUID, s.Title.Line, shared.FormatTimestamp(s.Title.Timestamp), s.Title.Content)
+		if err != nil {
+			return fmt.Errorf("failed to save summary: %w", err)
+		}
+		leaves = append(leaves, s.LeafUUID)
+	}
+
+	// Entries saved by this update may be leaves summarized earlier
+	entries := make([]string, len(u.Entries))
+	for i, entry := range u.Entries {
+		entries[i] = entry.UUID
+	}
+	if len(leaves) == 0 && len(entries) == 0 && !u.Prune {
+		return nil
+	}
+
+	leafJSON, err := json.Marshal(leaves)
+	if err != nil {
+		return fmt.Errorf("failed to encode summaries: %w", err)
+	}
+	entryJSON, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode entries: %w", err)
+	}
+
+	rows, err := q.Query(`
+		WITH targets(uuid) AS (
+			SELECT conversation_uuid FROM entries
+			WHERE uuid IN (SELECT value FROM json_each(?))
+			UNION
+			SELECT ? WHERE ? OR EXISTS (
+				SELECT 1 FROM summaries WHERE leaf_uuid IN (SELECT value FROM json_each(?))
+			)
+		)
+		SELECT e.conversation_uuid, s.conversation_uuid, COALESCE(s.line, 0), s.timestamp, s.title
+		FROM targets t
+		JOIN entries e ON e.conversation_uuid = t.uuid
+		JOIN summaries s ON s.leaf_uuid = e.uuid
+		ORDER BY s.rowid
+	`, string(leafJSON), uuid, u.Prune, string(entryJSON))
+	if err != nil {
+		return fmt.Errorf("failed to find summarized conversations: %w", err)
+	}
+
+	// Later rows are later summaries, replacing earlier titles
+	var order []string
+	titles := make(map[string]Message)
+	for rows.Next() {
+		var title Message
+		var source, timestamp string
+		if err := rows.Scan(&title.ConversationUUID, &source, &title.Line, &timestamp, &title.Content); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan summary: %w", err)
+		}
+		if title.Timestamp, err = shared.ParseTimestamp(timestamp); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to parse summary timestamp: %w", err)
+		}
+		// The line is only meaningful in the summary's own transcript
+		if source != title.ConversationUUID {
+			title.Line = 0
+		}
+		if _, seen := titles[title.ConversationUUID]; !seen {
+			order = append(order, title.ConversationUUID)
+		}
+		titles[title.ConversationUUID] = title
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return fmt.Errorf("failed to read summaries: %w", err)
+	}
+	rows.Close()
+
+	for _, conv := range order {
+		if err := setTitle(q, titles[conv]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
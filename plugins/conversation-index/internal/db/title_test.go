@@ -0,0 +1,146 @@
+package db
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSQLiteDB_SetTitle(t *testing.T) {
+	db := seedRankingDB(t, map[string][]Message{
+		"titled": {
+			{Role: "user", Content: "the deploy failed again"},
+		},
+		"mentions": {
+			{Role: "user", Content: "rollback strategy for the deploy"},
+			{Role: "assistant", Content: "a rollback needs the previous image"},
+		},
+	}, nil)
+
+	for _, title := range []string{"Investigate flaky deploy", "Rollback after failed deploy"} {
+		err := db.SetTitle(Message{ConversationUUID: "titled", Timestamp: time.Now(), Content: title, Line: 1})
+		if err != nil {
+			t.Fatalf("failed to set title: %v", err)
+		}
+	}
+
+	page, err := db.Search(SearchOptions{Query: "rollback", Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if page.TotalMatches != 2 {
+		t.Fatalf("expected 2 matches, got %d", page.TotalMatches)
+	}
+
+	// A single title hit outranks two message hits
+	top := page.Matches[0]
+	if top.UUID != "titled" || top.Title != "Rollback after failed deploy" {
+		t.Errorf("expected titled conversation first with its latest title, got %+v", top)
+	}
+	if top.MessageCount != 1 {
+		t.Errorf("expected the title not to count as a message, got %d", top.MessageCount)
+	}
+	if page.Matches[1].Title != "" {
+		t.Errorf("expected no title for untitled conversation, got %q", page.Matches[1].Title)
+	}
+
+	// The replaced title is no longer searchable
+	page, err = db.Search(SearchOptions{Query: "flaky", Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if page.TotalMatches != 0 {
+		t.Errorf("expected old title to be gone, got %d matches", page.TotalMatches)
+	}
+
+	page, err = db.Search(SearchOptions{Query: "deploy", Scope: ScopeAllProjects, Roles: []string{"title"}})
+	if err != nil {
+		t.Fatalf("failed to search titles: %v", err)
+	}
+	if page.TotalMatches != 1 || page.Matches[0].UUID != "titled" {
+		t.Errorf("expected role filter to match the title only, got %+v", page.Matches)
+	}
+
+	if err := db.DeleteConversation("titled"); err != nil {
+		t.Fatalf("failed to delete conversation: %v", err)
+	}
+	page, err = db.Search(SearchOptions{Query: "rollback", Scope: ScopeAllProjects, Roles: []string{"title"}})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if page.TotalMatches != 0 {
+		t.Errorf("expected title to be deleted with the conversation, got %d matches", page.TotalMatches)
+	}
+}
+
+func TestSQLiteDB_SaveConversations_Summaries(t *testing.T) {
+	db := seedRankingDB(t, nil, nil)
+	now := time.Now()
+
+	update := func(uuid string, entries []string, summaries ...Summary) ConversationUpdate {
+		u := ConversationUpdate{
+			Conversation: &Conversation{
+				UUID:        uuid,
+				ProjectPath: "/Users/test/project",
+				EncodedPath: "-Users-test-project",
+				CreatedAt:   now,
+				LastUpdated: now,
+			},
+			Summaries: summaries,
+			State:     &IndexState{ConversationUUID: uuid, LastModifiedTime: now},
+		}
+		for i, entry := range entries {
+			u.Entries = append(u.Entries, Entry{ConversationUUID: uuid, UUID: entry, Line: len(summaries) + i + 1})
+			u.Messages = append(u.Messages, Message{ConversationUUID: uuid, Timestamp: now, Role: "user", Content: "the deploy failed", Line: len(summaries) + i + 1, EntryUUID: entry})
+		}
+		return u
+	}
+	summary := func(uuid, leaf, title string, line int) Summary {
+		return Summary{LeafUUID: leaf, Title: Message{ConversationUUID: uuid, Timestamp: now, Content: title, Line: line}}
+	}
+	titles := func() map[string]string {
+		t.Helper()
+		page, err := db.Search(SearchOptions{Query: "deploy", Scope: ScopeAllProjects})
+		if err != nil {
+			t.Fatalf("failed to search: %v", err)
+		}
+		titles := make(map[string]string)
+		for _, match := range page.Matches {
+			titles[match.UUID] = match.Title
+		}
+		return titles
+	}
+	save := func(updates ...ConversationUpdate) {
+		t.Helper()
+		if err := db.SaveConversations(updates); err != nil {
+			t.Fatalf("failed to save conversations: %v", err)
+		}
+	}
+
+	// A resumed session starts with the summary of the one it resumes, which
+	// isn't indexed yet
+	save(update("resumed", []string{"r1"}, summary("resumed", "o2", "Fix the flaky deploy", 1)))
+	if got := titles(); got["resumed"] != "" {
+		t.Errorf("expected the resumed session not to take the summary, got %q", got["resumed"])
+	}
+
+	save(update("original", []string{"o1", "o2"}))
+	if got := titles(); got["original"] != "Fix the flaky deploy" || got["resumed"] != "" {
+		t.Errorf("expected the summary to title the conversation holding its leaf, got %v", got)
+	}
+
+	// Summaries of conversations already indexed title them straight away,
+	// and a later summary replaces an earlier one
+	save(update("again", []string{"a1"}, summary("again", "o2", "Deploy retries", 1), summary("again", "r1", "Resume the deploy", 2)))
+	if got := titles(); got["original"] != "Deploy retries" || got["resumed"] != "Resume the deploy" || got["again"] != "" {
+		t.Errorf("expected each summary to title its own leaf's conversation, got %v", got)
+	}
+
+	// The title isn't attributed to a line of another transcript
+	page, err := db.SearchMessages(SearchOptions{Query: "retries", Scope: ScopeAllProjects, Roles: []string{"title"}})
+	if err != nil {
+		t.Fatalf("failed to search messages: %v", err)
+	}
+	if len(page.Messages) != 1 || page.Messages[0].ConversationUUID != "original" || page.Messages[0].Line != 0 {
+		t.Errorf("expected the title on the original conversation without a line, got %+v", page.Messages)
+	}
+}
@@ -0,0 +1,154 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// tokenizerSetting is the settings key holding the messages_fts tokenizer
+const tokenizerSetting = "fts_tokenizer"
+
+// Tokenizer configures how messages_fts splits text into words
+type Tokenizer struct {
+	Porter           bool   // Stem words, so "indexing" matches "indexed"
+	RemoveDiacritics int    // unicode61 remove_diacritics: 0, 1 or 2
+	TokenChars       string // Extra characters treated as part of a word, e.g. "_."
+}
+
+// DefaultTokenizer is plain unicode61, which messages_fts was created with
+func DefaultTokenizer() Tokenizer {
+	return Tokenizer{RemoveDiacritics: 1}
+}
+
+// Validate checks the tokenizer options are usable
+func (t Tokenizer) Validate() error {
+	if t.RemoveDiacritics < 0 || t.RemoveDiacritics > 2 {
+		return fmt.Errorf("remove_diacritics must be 0, 1 or 2, got %d", t.RemoveDiacritics)
+	}
+	for _, r := range t.TokenChars {
+		if r > unicode.MaxASCII || !unicode.IsPunct(r) && !unicode.IsSymbol(r) || r == '\'' || r == '"' {
+			return fmt.Errorf("token characters must be ASCII punctuation other than quotes, got %q", r)
+		}
+	}
+	return nil
+}
+
+// String returns the FTS5 tokenize option for t
+func (t Tokenizer) String() string {
+	var parts []string
+	if t.Porter {
+		parts = append(parts, "porter")
+	}
+	parts = append(parts, "unicode61", fmt.Sprintf("remove_diacritics %d", t.RemoveDiacritics))
+	if t.TokenChars != "" {
+		parts = append(parts, "tokenchars '"+t.TokenChars+"'")
+	}
+	return strings.Join(parts, " ")
+}
+
+// messagesFTSStatements recreate messages_fts with a tokenizer, along with
+// the triggers keeping it in sync, and fill it from messages
+func messagesFTSStatements(t Tokenizer) []string {
+	return []string{
+		`DROP TRIGGER IF EXISTS messages_ai`,
+		`DROP TRIGGER IF EXISTS messages_ad`,
+		`DROP TRIGGER IF EXISTS messages_au`,
+		`DROP TABLE IF EXISTS messages_fts`,
+		`CREATE VIRTUAL TABLE messages_fts USING fts5(
+			conversation_uuid,
+			content,
+			content=messages,
+			content_rowid=id,
+			tokenize="` + t.String() + `"
+		)`,
+		`CREATE TRIGGER messages_ai AFTER INSERT ON messages BEGIN
+			INSERT INTO messages_fts(rowid, conversation_uuid, content)
+			VALUES (new.id, new.conversation_uuid, new.content);
+		END`,
+		`CREATE TRIGGER messages_ad AFTER DELETE ON messages BEGIN
+			INSERT INTO messages_fts(messages_fts, rowid, conversation_uuid, content)
+			VALUES ('delete', old.id, old.conversation_uuid, old.content);
+		END`,
+		`CREATE TRIGGER messages_au AFTER UPDATE ON messages BEGIN
+			INSERT INTO messages_fts(messages_fts, rowid, conversation_uuid, content)
+			VALUES ('delete', old.id, old.conversation_uuid, old.content);
+			INSERT INTO messages_fts(rowid, conversation_uuid, content)
+			VALUES (new.id, new.conversation_uuid, new.content);
+		END`,
+		`INSERT INTO messages_fts(messages_fts) VALUES ('rebuild')`,
+	}
+}
+
+// Tokenizer returns the tokenize option messages_fts was built with
+func (db *sqliteDB) Tokenizer() (string, error) {
+	var spec string
+	err := db.conn.QueryRow(`SELECT value FROM settings WHERE key = ?`, tokenizerSetting).Scan(&spec)
+	if err == sql.ErrNoRows {
+		return DefaultTokenizer().String(), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read tokenizer setting: %w", err)
+	}
+	return spec, nil
+}
+
+// SetTokenizer rebuilds messages_fts from the messages table if it was built
+// with a different tokenizer, and reports whether it did. Transcripts don't
+// need to be rescanned.
+func (db *sqliteDB) SetTokenizer(t Tokenizer) (bool, error) {
+	if err := t.Validate(); err != nil {
+		return false, fmt.Errorf("invalid tokenizer: %w", err)
+	}
+	spec := t.String()
+
+	ctx := context.Background()
+	conn, err := db.conn.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to get connection: %w", err)
+	}
+	defer conn.Close()
+
+	// Checked again under the write lock, in case another process got here first
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			conn.ExecContext(ctx, "ROLLBACK")
+		}
+	}()
+
+	current := DefaultTokenizer().String()
+	err = conn.QueryRowContext(ctx, `SELECT value FROM settings WHERE key = ?`, tokenizerSetting).Scan(&current)
+	if err != nil && err != sql.ErrNoRows {
+		return false, fmt.Errorf("failed to read tokenizer setting: %w", err)
+	}
+	if current == spec {
+		return false, nil
+	}
+
+	for _, stmt := range messagesFTSStatements(t) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return false, fmt.Errorf("failed to rebuild search index: %w", err)
+		}
+	}
+
+	_, err = conn.ExecContext(ctx, `
+		INSERT INTO settings (key, value) VALUES (?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value
+	`, tokenizerSetting, spec)
+	if err != nil {
+		return false, fmt.Errorf("failed to save tokenizer setting: %w", err)
+	}
+
+	if _, err := conn.ExecContext(ctx, "COMMIT"); err != nil {
+		return false, fmt.Errorf("failed to commit rebuild: %w", err)
+	}
+	committed = true
+
+	return true, nil
+}
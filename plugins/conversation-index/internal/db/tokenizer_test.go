@@ -0,0 +1,145 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTokenizer_String(t *testing.T) {
+	tests := []struct {
+		tokenizer Tokenizer
+		want      string
+	}{
+		{DefaultTokenizer(), "unicode61 remove_diacritics 1"},
+		{Tokenizer{Porter: true, RemoveDiacritics: 2}, "porter unicode61 remove_diacritics 2"},
+		{Tokenizer{RemoveDiacritics: 0, TokenChars: "_."}, "unicode61 remove_diacritics 0 tokenchars '_.'"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.tokenizer.String(); got != tt.want {
+			t.Errorf("%+v.String() = %q, want %q", tt.tokenizer, got, tt.want)
+		}
+	}
+}
+
+func TestTokenizer_Validate(t *testing.T) {
+	valid := []Tokenizer{DefaultTokenizer(), {RemoveDiacritics: 2, TokenChars: "_.-/"}}
+	for _, tok := range valid {
+		if err := tok.Validate(); err != nil {
+			t.Errorf("%+v: unexpected error: %v", tok, err)
+		}
+	}
+
+	invalid := []Tokenizer{
+		{RemoveDiacritics: 3},
+		{RemoveDiacritics: 1, TokenChars: "'"},
+		{RemoveDiacritics: 1, TokenChars: "a"},
+		{RemoveDiacritics: 1, TokenChars: " "},
+	}
+	for _, tok := range invalid {
+		if err := tok.Validate(); err == nil {
+			t.Errorf("%+v: expected error, got nil", tok)
+		}
+	}
+}
+
+func TestSQLiteDB_SetTokenizer(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.InitSchema(); err != nil {
+		t.Fatalf("failed to initialize schema: %v", err)
+	}
+
+	conv := &Conversation{
+		UUID:        "conv-1",
+		ProjectPath: "/Users/test/project",
+		EncodedPath: "-Users-test-project",
+		CreatedAt:   time.Now(),
+		LastUpdated: time.Now(),
+	}
+	if err := db.SaveConversation(conv); err != nil {
+		t.Fatalf("failed to save conversation: %v", err)
+	}
+	save := func(content string) {
+		t.Helper()
+		err := db.SaveMessages([]Message{{ConversationUUID: "conv-1", Timestamp: time.Now(), Role: "user", Content: content}})
+		if err != nil {
+			t.Fatalf("failed to save messages: %v", err)
+		}
+	}
+	count := func(query string) int {
+		t.Helper()
+		messagePage, err := db.SearchMessages(SearchOptions{Query: query, Scope: ScopeAllProjects})
+		if err != nil {
+			t.Fatalf("search %q failed: %v", query, err)
+		}
+		matches := messagePage.Messages
+		return len(matches)
+	}
+
+	save("we indexed the naïve café menu")
+	save("rename snake_case fields")
+
+	if spec, _ := db.Tokenizer(); spec != DefaultTokenizer().String() {
+		t.Errorf("expected default tokenizer, got %q", spec)
+	}
+	if changed, err := db.SetTokenizer(DefaultTokenizer()); err != nil || changed {
+		t.Fatalf("expected default tokenizer to be a no-op, got changed=%v err=%v", changed, err)
+	}
+	if n := count("indexing"); n != 0 {
+		t.Errorf("expected no stemmed match before rebuild, got %d", n)
+	}
+
+	changed, err := db.SetTokenizer(Tokenizer{Porter: true, RemoveDiacritics: 2, TokenChars: "_"})
+	if err != nil {
+		t.Fatalf("failed to set tokenizer: %v", err)
+	}
+	if !changed {
+		t.Fatal("expected index to be rebuilt")
+	}
+	if spec, _ := db.Tokenizer(); spec != "porter unicode61 remove_diacritics 2 tokenchars '_'" {
+		t.Errorf("unexpected tokenizer after rebuild: %q", spec)
+	}
+
+	if n := count("indexing"); n != 1 {
+		t.Errorf("expected stemmed match after rebuild, got %d", n)
+	}
+	if n := count("naive cafe"); n != 1 {
+		t.Errorf("expected diacritics to fold, got %d", n)
+	}
+	if n := count("snake_case"); n != 1 {
+		t.Errorf("expected snake_case as one token, got %d", n)
+	}
+	if n := count("snake"); n != 0 {
+		t.Errorf("expected snake not to match inside snake_case, got %d", n)
+	}
+
+	// The rebuilt triggers keep the index in sync
+	save("indexes everywhere")
+	if n := count("indexing"); n != 2 {
+		t.Errorf("expected new message to be indexed, got %d", n)
+	}
+	if err := db.DeleteConversation("conv-1"); err != nil {
+		t.Fatalf("failed to delete conversation: %v", err)
+	}
+	if n := count("indexing"); n != 0 {
+		t.Errorf("expected deleted messages to leave the index, got %d", n)
+	}
+
+	conn := db.(*sqliteDB).conn
+	if _, err := conn.Exec(`INSERT INTO messages_fts(messages_fts, rank) VALUES ('integrity-check', 1)`); err != nil {
+		t.Errorf("expected FTS integrity check to pass: %v", err)
+	}
+
+	if changed, err := db.SetTokenizer(Tokenizer{Porter: true, RemoveDiacritics: 2, TokenChars: "_"}); err != nil || changed {
+		t.Errorf("expected unchanged tokenizer to be a no-op, got changed=%v err=%v", changed, err)
+	}
+	if _, err := db.SetTokenizer(Tokenizer{RemoveDiacritics: 5}); err == nil {
+		t.Error("expected error for invalid tokenizer")
+	}
+}
@@ -0,0 +1,138 @@
+package db
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// SaveEntries upserts the tree entries of a conversation in a transaction
+func (db *sqliteDB) SaveEntries(entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return db.inTx(func(tx *sql.Tx) error { return saveEntries(tx, entries) })
+}
+
+func saveEntries(q execer, entries []Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	stmt, err := q.Prepare(`
+		INSERT INTO entries (conversation_uuid, uuid, parent_uuid, line, sidechain)
+		VALUES (?, ?, NULLIF(?, ''), ?, ?)
+		ON CONFLICT(conversation_uuid, uuid) DO UPDATE SET
+			parent_uuid = excluded.parent_uuid,
+			line = excluded.line,
+			sidechain = excluded.sidechain
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, entry := range entries {
+		if _, err := stmt.Exec(entry.ConversationUUID, entry.UUID, entry.ParentUUID, entry.Line, entry.Sidechain); err != nil {
+			return fmt.Errorf("failed to insert entry: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// UpdateActiveBranch marks the messages of a conversation that are off its
+// active branch, the path from the latest entry back to the root, as
+// abandoned. Sidechain entries inside a main transcript are branches of
+// their own and are left alone. Only messages whose state changes are written.
+func (db *sqliteDB) UpdateActiveBranch(uuid string) error {
+	return updateActiveBranch(db.conn, uuid)
+}
+
+func updateActiveBranch(q execer, uuid string) error {
+	query := `
+		WITH RECURSIVE
+		tree(sidechain) AS (
+			SELECT parent_uuid IS NOT NULL FROM conversations WHERE uuid = ?
+		),
+		active(uuid) AS (
+			SELECT uuid FROM (
+				SELECT uuid FROM entries
+				WHERE conversation_uuid = ? AND sidechain = (SELECT sidechain FROM tree)
+				ORDER BY line DESC LIMIT 1
+			)
+			UNION
+			SELECT e.parent_uuid
+			FROM entries e
+			JOIN active a ON e.uuid = a.uuid
+			WHERE e.conversation_uuid = ? AND e.parent_uuid IS NOT NULL
+		)
+		UPDATE messages
+		SET abandoned = NOT abandoned
+		WHERE conversation_uuid = ?
+			AND entry_uuid IS NOT NULL
+			AND sidechain = (SELECT sidechain FROM tree)
+			AND abandoned = (entry_uuid IN (SELECT uuid FROM active))
+			AND EXISTS (SELECT 1 FROM active)
+	`
+
+	if _, err := q.Exec(query, uuid, uuid, uuid, uuid); err != nil {
+		return fmt.Errorf("failed to update active branch: %w", err)
+	}
+
+	return nil
+}
+
+// PruneConversation removes what a conversation's transcript no longer
+// contains before it is read again from the start: entries and their
+// messages not in keepEntries, messages without an entry, the title, and all
+// summaries and tool calls. Messages of kept entries stay, and are updated in place when
+// they are saved again.
+func (db *sqliteDB) PruneConversation(uuid string, keepEntries []string) error {
+	return db.inTx(func(tx *sql.Tx) error { return pruneConversation(tx, uuid, keepEntries) })
+}
+
+func pruneConversation(q execer, uuid string, keepEntries []string) error {
+	if keepEntries == nil {
+		keepEntries = []string{}
+	}
+	keep, err := json.Marshal(keepEntries)
+	if err != nil {
+		return fmt.Errorf("failed to encode entries: %w", err)
+	}
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{`DELETE FROM messages WHERE conversation_uuid = ?
+			AND (entry_uuid IS NULL OR entry_uuid NOT IN (SELECT value FROM json_each(?)))`, []interface{}{uuid, string(keep)}},
+		{`DELETE FROM entries WHERE conversation_uuid = ?
+			AND uuid NOT IN (SELECT value FROM json_each(?))`, []interface{}{uuid, string(keep)}},
+		{`DELETE FROM tool_calls WHERE conversation_uuid = ?`, []interface{}{uuid}},
+		{`DELETE FROM summaries WHERE conversation_uuid = ?`, []interface{}{uuid}},
+		{`UPDATE conversations SET title = NULL WHERE uuid = ?`, []interface{}{uuid}},
+	}
+	for _, stmt := range statements {
+		if _, err := q.Exec(stmt.query, stmt.args...); err != nil {
+			return fmt.Errorf("failed to prune conversation: %w", err)
+		}
+	}
+
+	return updateMessageCount(q, uuid)
+}
+
+// updateMessageCount recounts a conversation's messages, not counting its title
+func updateMessageCount(q execer, uuid string) error {
+	_, err := q.Exec(`
+		UPDATE conversations
+		SET message_count = (
+			SELECT COUNT(*) FROM messages WHERE conversation_uuid = ? AND role != 'title'
+		)
+		WHERE uuid = ?
+	`, uuid, uuid)
+	if err != nil {
+		return fmt.Errorf("failed to update message count: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSQLiteDB_ConversationTree(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.InitSchema(); err != nil {
+		t.Fatalf("failed to initialize schema: %v", err)
+	}
+
+	conv := &Conversation{
+		UUID:        "conv-1",
+		ProjectPath: "/Users/test/project",
+		EncodedPath: "-Users-test-project",
+		CreatedAt:   time.Now(),
+		LastUpdated: time.Now(),
+	}
+	if err := db.SaveConversation(conv); err != nil {
+		t.Fatalf("failed to save conversation: %v", err)
+	}
+
+	// The retried answer r2 replaces r1
+	entries := []Entry{
+		{UUID: "u1", Line: 1},
+		{UUID: "r1", ParentUUID: "u1", Line: 2},
+		{UUID: "r2", ParentUUID: "u1", Line: 3},
+	}
+	messages := []Message{
+		{EntryUUID: "u1", Role: "user", Content: "tune the connection pool"},
+		{EntryUUID: "r1", Role: "assistant", Content: "first connection pool attempt"},
+		{EntryUUID: "r2", Role: "assistant", Content: "second connection pool attempt"},
+		{EntryUUID: "r2", Part: 1, Role: "tool", Content: "Tool: Edit File: pool.go"},
+	}
+	for i := range entries {
+		entries[i].ConversationUUID = conv.UUID
+	}
+	for i := range messages {
+		messages[i].ConversationUUID = conv.UUID
+		messages[i].Timestamp = time.Now()
+	}
+
+	// Saving twice upserts on the entry instead of duplicating
+	for i := 0; i < 2; i++ {
+		if err := db.SaveEntries(entries); err != nil {
+			t.Fatalf("failed to save entries: %v", err)
+		}
+		if err := db.SaveMessages(messages); err != nil {
+			t.Fatalf("failed to save messages: %v", err)
+		}
+	}
+	if err := db.UpdateActiveBranch(conv.UUID); err != nil {
+		t.Fatalf("failed to update active branch: %v", err)
+	}
+
+	search := func(query string, includeAbandoned bool) []MessageMatch {
+		t.Helper()
+		page, err := db.SearchMessages(SearchOptions{Query: query, Scope: ScopeAllProjects, IncludeAbandoned: includeAbandoned})
+		if err != nil {
+			t.Fatalf("failed to search: %v", err)
+		}
+		return page.Messages
+	}
+
+	if got := search("connection pool", false); len(got) != 2 {
+		t.Errorf("expected the active user message and answer, got %+v", got)
+	}
+	if got := search("first", false); len(got) != 0 {
+		t.Errorf("expected the abandoned answer to be hidden, got %+v", got)
+	}
+	got := search("first", true)
+	if len(got) != 1 || !got[0].Abandoned {
+		t.Errorf("expected the abandoned answer flagged with IncludeAbandoned, got %+v", got)
+	}
+
+	page, err := db.Search(SearchOptions{Query: "pool", Scope: ScopeAllProjects})
+	if err != nil {
+		t.Fatalf("failed to search: %v", err)
+	}
+	if len(page.Matches) != 1 || page.Matches[0].MessageCount != 4 || page.Matches[0].Hits != 3 {
+		t.Errorf("expected 4 messages and 3 active hits, got %+v", page.Matches)
+	}
+
+	// Pruning to the entries still in the transcript drops the rest
+	if err := db.PruneConversation(conv.UUID, []string{"u1", "r1"}); err != nil {
+		t.Fatalf("failed to prune conversation: %v", err)
+	}
+	if err := db.UpdateActiveBranch(conv.UUID); err != nil {
+		t.Fatalf("failed to update active branch: %v", err)
+	}
+	if got := search("second", true); len(got) != 0 {
+		t.Errorf("expected pruned messages to be gone, got %+v", got)
+	}
+	got = search("first", false)
+	if len(got) != 1 || got[0].Abandoned {
+		t.Errorf("expected r1 back on the active branch, got %+v", got)
+	}
+}
@@ -4,47 +4,288 @@ import "time"
 
 // Conversation represents a conversation metadata record
 type Conversation struct {
-	UUID         string
-	ProjectPath  string
-	EncodedPath  string
-	CreatedAt    time.Time
-	LastUpdated  time.Time
-	MessageCount int
+	UUID           string
+	ProjectPath    string // Working directory; saving without one keeps the existing path
+	EncodedPath    string
+	CreatedAt      time.Time
+	LastUpdated    time.Time
+	MessageCount   int
+	TranscriptPath string // Path of the JSONL file the conversation was indexed from
+	Title          string // Title from the transcript's latest summary entry
+	ParentUUID     string // For a subagent's sidechain, the conversation that started it
+	SubagentType   string // For a sidechain, the subagent_type of the Task call that started it
+	SessionMetadata
+}
+
+// SessionMetadata is what a transcript records about its Claude Code
+// session. Each field holds the latest value seen.
+type SessionMetadata struct {
+	SessionID      string
+	GitBranch      string
+	ClaudeVersion  string
+	Model          string
+	PermissionMode string
+}
+
+// Merge overwrites fields of s with the non-empty fields of other
+func (s *SessionMetadata) Merge(other SessionMetadata) {
+	if other.SessionID != "" {
+		s.SessionID = other.SessionID
+	}
+	if other.GitBranch != "" {
+		s.GitBranch = other.GitBranch
+	}
+	if other.ClaudeVersion != "" {
+		s.ClaudeVersion = other.ClaudeVersion
+	}
+	if other.Model != "" {
+		s.Model = other.Model
+	}
+	if other.PermissionMode != "" {
+		s.PermissionMode = other.PermissionMode
+	}
 }
 
 // Message represents a single message in a conversation
 type Message struct {
-	ID              int64
+	ID               int64
 	ConversationUUID string
-	Timestamp       time.Time
-	Role            string // user, assistant, tool
-	Content         string
+	Timestamp        time.Time
+	Role             string // user, assistant, thinking, tool, tool_result, title
+	Content          string
+	Line             int    // 1-based line of the entry in the transcript JSONL
+	Model            string // Model that wrote an assistant or tool message
+	IsError          bool   // A tool_result the tool reported as an error
+	EntryUUID        string // uuid of the transcript entry, empty for entries without one
+	Part             int    // Position among the messages extracted from the entry
+	Abandoned        bool   // Off the conversation's active branch, set by UpdateActiveBranch
+	Sidechain        bool   // Written by a subagent
+}
+
+// Entry is a node of a conversation's tree. Edited prompts and retries fork
+// the tree; the active branch runs from the latest entry back to the root.
+type Entry struct {
+	ConversationUUID string
+	UUID             string
+	ParentUUID       string // Empty for a root
+	Line             int    // 1-based line of the entry in the transcript JSONL
+	Sidechain        bool   // Written by a subagent
+}
+
+// ToolCall is a structured record of a tool use. The same call is also
+// indexed as a flattened "tool" message for full-text search.
+type ToolCall struct {
+	ConversationUUID string
+	Timestamp        time.Time
+	Tool             string
+	FilePath         string // file_path or notebook_path input, for file tools
+	Command          string // Bash command
+	Pattern          string // Grep or Glob pattern
+	SubagentType     string // Task subagent_type
+	Prompt           string // Task prompt, which starts the subagent's sidechain
+	Line             int    // 1-based line of the entry in the transcript JSONL
+}
+
+// ConversationUpdate is everything indexing a transcript writes for its
+// conversation, saved together by SaveConversations
+type ConversationUpdate struct {
+	Conversation *Conversation // Nil when only State changed
+	Prune        bool          // Remove what the transcript no longer contains, after a rollback
+	KeepEntries  []string      // Entries still in the transcript, when pruning
+	Entries      []Entry
+	Messages     []Message
+	ToolCalls    []ToolCall
+	Summaries    []Summary // Summary entries, in transcript order
+	State        *IndexState
+}
+
+// Summary is a summary entry. It titles the conversation holding its leaf
+// entry, which is often not the transcript it was written to: Claude Code
+// writes the summaries of earlier sessions at the top of a resumed one.
+type Summary struct {
+	LeafUUID string  // Last entry summarized; empty in older transcripts, which title their own conversation
+	Title    Message // Title text, and the conversation and line of the summary entry
+}
+
+// MaintenanceReport describes what Maintain checked and fixed
+type MaintenanceReport struct {
+	SizeBefore    int64    // Database size in bytes before maintenance
+	SizeAfter     int64    // Database size in bytes after vacuuming
+	IndexProblems []string // Failed search index checks; each index was rebuilt
+	CountsFixed   int      // Conversations whose message_count was wrong
 }
 
 // IndexState tracks the indexing progress for a conversation
 type IndexState struct {
-	ConversationUUID  string
-	LastIndexedLine   int
-	LastModifiedTime  time.Time
+	ConversationUUID string
+	LastIndexedLine  int
+	LastModifiedTime time.Time
+	ByteOffset       int64  // Just past the last indexed line
+	FileSize         int64  // Size of the transcript when it was indexed
+	Inode            uint64 // 0 where files have no inode numbers
+	PrefixChecksum   uint32 // CRC-32C of the start and end of the transcript up to ByteOffset
+}
+
+// Search scopes
+const (
+	ScopeCurrentProject = "current_project"
+	ScopeAllProjects    = "all_projects"
+)
+
+// Roles are the message roles stored in the index
+var Roles = []string{"user", "assistant", "thinking", "tool", "tool_result", "title"}
+
+// IsValidRole reports whether role is one of the indexed message roles
+func IsValidRole(role string) bool {
+	for _, r := range Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// SearchOptions controls which conversations a search returns. Zero values
+// disable the corresponding filter.
+type SearchOptions struct {
+	Query             string    // Query in the ParseQuery language, or FTS5 syntax if Raw
+	Raw               bool      // Pass Query to FTS5 MATCH unparsed
+	Substring         bool      // Match terms anywhere within words, via messages_trigram
+	Scope             string    // ScopeCurrentProject or ScopeAllProjects
+	ProjectPath       string    // Encoded project path for ScopeCurrentProject
+	Limit             int       // Maximum conversations, <= 0 for no limit
+	Offset            int       // Results to skip, for the first page
+	Cursor            string    // NextCursor of the previous page, in place of Offset
+	Now               time.Time // Reference time for relative dates and recency, zero for now
+	Excerpts          int       // Excerpts per match, 0 for the default, < 0 for none
+	Since             time.Time // Only match messages at or after this time
+	Until             time.Time // Only match messages before this time
+	Roles             []string  // Only match messages with one of these roles
+	MinMessages       int       // Minimum conversation message count
+	MaxMessages       int       // Maximum conversation message count
+	Branches          []string  // Only match conversations last on one of these git branches
+	Models            []string  // Only match conversations with a message from a model containing one of these
+	ExcludeUUIDs      []string  // Conversations to leave out of the results
+	ErrorsOnly        bool      // Only match tool results that reported an error
+	IncludeAbandoned  bool      // Also match messages on abandoned branches
+	HideSidechainOnly bool      // Leave out conversations matched only by their subagents
+
+	Weights *RankingWeights // Ranking weights, nil for DefaultRankingWeights
+
+	parsed   *ParsedQuery // Set once Query has been compiled to FTS5
+	snapshot int64        // Highest message id visible to a paged search
 }
 
 // Match represents a search result
 type Match struct {
-	UUID           string  `json:"uuid"`
-	ProjectPath    string  `json:"project_path"`
-	EncodedPath    string  `json:"encoded_path"`
-	CreatedAt      string  `json:"created_at"`
-	LastUpdated    string  `json:"last_updated"`
-	MessageCount   int     `json:"message_count"`
-	Summary        string  `json:"summary"`
-	RelevanceScore float64 `json:"relevance_score"`
+	UUID           string    `json:"uuid"`
+	ProjectPath    string    `json:"project_path"`
+	EncodedPath    string    `json:"encoded_path"`
+	CreatedAt      string    `json:"created_at"`
+	LastUpdated    string    `json:"last_updated"`
+	MessageCount   int       `json:"message_count"`
+	Title          string    `json:"title,omitempty"`
+	GitBranch      string    `json:"git_branch,omitempty"`
+	Model          string    `json:"model,omitempty"`
+	Summary        string    `json:"summary"`
+	Hits           int       `json:"hits"`
+	SidechainHits  int       `json:"sidechain_hits,omitempty"` // Hits in subagent sidechains, included in Hits
+	RelevanceScore float64   `json:"relevance_score"`
+	Excerpts       []Excerpt `json:"excerpts"`
+}
+
+// Excerpt is a fragment of a matching message with the hit terms marked
+type Excerpt struct {
+	Role       string      `json:"role"`
+	Timestamp  string      `json:"timestamp"`
+	Line       int         `json:"line,omitempty"`
+	IsError    bool        `json:"is_error,omitempty"`
+	Abandoned  bool        `json:"abandoned,omitempty"`
+	Sidechain  bool        `json:"sidechain,omitempty"`
+	Subagent   string      `json:"subagent_type,omitempty"`
+	Text       string      `json:"text"`
+	Highlights []Highlight `json:"highlights"`
+}
+
+// Highlight is a matched term within an excerpt, as byte offsets into Text
+type Highlight struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// MessageMatch is a single matching message from a message-level search
+type MessageMatch struct {
+	ConversationUUID string  `json:"conversation_uuid"`
+	ParentUUID       string  `json:"parent_uuid,omitempty"`
+	ProjectPath      string  `json:"project_path"`
+	TranscriptPath   string  `json:"transcript_path,omitempty"`
+	RelevanceScore   float64 `json:"relevance_score"`
+	Excerpt
+}
+
+// SearchParams echoes the search request in a response
+type SearchParams struct {
+	Query             string   `json:"query"`
+	Scope             string   `json:"scope"`
+	Substring         bool     `json:"substring,omitempty"`
+	CurrentProject    string   `json:"current_project,omitempty"`
+	Since             string   `json:"since,omitempty"`
+	Until             string   `json:"until,omitempty"`
+	Roles             []string `json:"roles,omitempty"`
+	MinMessages       int      `json:"min_messages,omitempty"`
+	MaxMessages       int      `json:"max_messages,omitempty"`
+	Branches          []string `json:"branches,omitempty"`
+	Models            []string `json:"models,omitempty"`
+	Exclude           []string `json:"exclude,omitempty"`
+	ErrorsOnly        bool     `json:"errors_only,omitempty"`
+	IncludeAbandoned  bool     `json:"include_abandoned,omitempty"`
+	HideSidechainOnly bool     `json:"hide_sidechain_only,omitempty"`
 }
 
 // SearchResult represents the full search response
 type SearchResult struct {
-	Query          string  `json:"query"`
-	Scope          string  `json:"scope"`
-	CurrentProject string  `json:"current_project,omitempty"`
-	TotalMatches   int     `json:"total_matches"`
-	Matches        []Match `json:"matches"`
+	SearchParams
+	PageInfo
+	Matches     []Match      `json:"matches"`
+	Suggestions []Suggestion `json:"suggestions,omitempty"`
+}
+
+// MessageSearchResult represents the response of a message-level search
+type MessageSearchResult struct {
+	SearchParams
+	PageInfo
+	Messages    []MessageMatch `json:"messages"`
+	Suggestions []Suggestion   `json:"suggestions,omitempty"`
+}
+
+// FileOptions selects the tool calls FilesTouched returns
+type FileOptions struct {
+	Path        string // Absolute file path, or a directory to match everything beneath it
+	ProjectPath string // Encoded project path to restrict to, empty for all projects
+	Limit       int    // Maximum conversations, <= 0 for no limit
+}
+
+// FileActivity is a conversation whose tool calls read, edited or wrote
+// files under a path
+type FileActivity struct {
+	UUID           string      `json:"uuid"`
+	ProjectPath    string      `json:"project_path"`
+	TranscriptPath string      `json:"transcript_path,omitempty"`
+	Title          string      `json:"title,omitempty"`
+	LastTouched    string      `json:"last_touched"`
+	Touches        []FileTouch `json:"touches"`
+}
+
+// FileTouch is a single tool call on a file, in transcript order
+type FileTouch struct {
+	Tool      string `json:"tool"`
+	FilePath  string `json:"file_path"`
+	Timestamp string `json:"timestamp"`
+	Line      int    `json:"line,omitempty"`
+}
+
+// FilesResult represents the response of a files lookup
+type FilesResult struct {
+	Path          string         `json:"path"`
+	Conversations []FileActivity `json:"conversations"`
 }
@@ -1,29 +1,50 @@
 package indexer
 
 import (
-	"bufio"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/db"
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/redact"
 )
 
 // Indexer coordinates the indexing of conversation files
 type Indexer struct {
-	db      db.DB
-	parser  *Parser
-	scanner *Scanner
+	db        db.DB
+	parser    *Parser
+	scanner   *Scanner
+	retention RetentionPolicy
+	redactor  *redact.Redactor
+	jobs      int
 }
 
-// NewIndexer creates a new indexer
+// IndexerOptions configures what an indexer stores and for how long
+type IndexerOptions struct {
+	Parser    ParserOptions
+	Retention RetentionPolicy
+	Redactor  *redact.Redactor // Replaces secrets before they're saved; nil saves content as is
+	Jobs      int              // Transcripts read and parsed at once, 0 for one per CPU
+}
+
+// NewIndexer creates a new indexer with the default options
 func NewIndexer(database db.DB, projectsDir string) *Indexer {
+	return NewIndexerWithOptions(database, projectsDir, IndexerOptions{})
+}
+
+// NewIndexerWithOptions creates a new indexer
+func NewIndexerWithOptions(database db.DB, projectsDir string, opts IndexerOptions) *Indexer {
 	return &Indexer{
-		db:      database,
-		parser:  NewParser(),
-		scanner: NewScanner(projectsDir),
+		db:        database,
+		parser:    NewParserWithOptions(opts.Parser),
+		scanner:   NewScanner(projectsDir),
+		retention: opts.Retention,
+		redactor:  opts.Redactor,
+		jobs:      opts.Jobs,
 	}
 }
 
@@ -32,6 +53,7 @@ type IndexStats struct {
 	TotalIndexed       int
 	TotalSkipped       int
 	TotalConversations int
+	TotalPruned        int
 }
 
 // IndexAll indexes all conversations, optionally doing a full reindex
@@ -57,29 +79,97 @@ func (idx *Indexer) IndexAll(fullReindex bool) error {
 		return fmt.Errorf("failed to scan conversations: %w", err)
 	}
 
-	// Index each conversation
+	// Index each conversation, leaving out those past retention
+	present := make(map[string]bool, len(files))
+	var current []ConversationFile
 	for _, file := range files {
-		indexed, skipped, err := idx.indexConversation(file)
+		present[file.UUID] = true
+		if !idx.retention.expired(file.ProjectPath, file.EncodedPath, time.Unix(0, file.LastModified), startTime) {
+			current = append(current, file)
+		}
+	}
+	idx.indexFiles(current, stats)
+
+	// Sidechains can be indexed before the conversation that started them
+	if err := idx.db.LinkSidechains(); err != nil {
+		return fmt.Errorf("failed to link sidechains: %w", err)
+	}
+
+	stats.TotalPruned, err = idx.pruneConversations(present, startTime)
+	if err != nil {
+		return fmt.Errorf("failed to prune conversations: %w", err)
+	}
+	if err := idx.db.SetLastPruned(startTime); err != nil {
+		return err
+	}
+
+	stats.log(time.Since(startTime))
+	return nil
+}
+
+// IndexPaths indexes the transcripts at paths, such as those a Watcher saw
+// change, and removes the conversations of any that were deleted
+func (idx *Indexer) IndexPaths(paths []string) error {
+	startTime := time.Now()
+	stats := &IndexStats{}
+
+	var current []ConversationFile
+	for _, path := range paths {
+		file, err := idx.scanner.Lookup(path)
+		if os.IsNotExist(err) {
+			removed, err := idx.removeTranscript(path)
+			if err != nil {
+				return err
+			}
+			if removed {
+				stats.TotalPruned++
+			}
+			continue
+		}
 		if err != nil {
-			log.Printf("Failed to index conversation %s: %v", file.UUID, err)
+			log.Printf("Skipping %s: %v", path, err)
 			continue
 		}
-
-		stats.TotalConversations++
-		if skipped {
-			stats.TotalSkipped++
-		} else {
-			stats.TotalIndexed += indexed
+		if !idx.retention.expired(file.ProjectPath, file.EncodedPath, time.Unix(0, file.LastModified), startTime) {
+			current = append(current, file)
 		}
 	}
+	idx.indexFiles(current, stats)
 
-	elapsed := time.Since(startTime)
-	log.Printf("Indexed %d messages from %d conversations (%d skipped) in %dms",
-		stats.TotalIndexed, stats.TotalConversations, stats.TotalSkipped, elapsed.Milliseconds())
+	if err := idx.db.LinkSidechains(); err != nil {
+		return fmt.Errorf("failed to link sidechains: %w", err)
+	}
 
+	stats.log(time.Since(startTime))
 	return nil
 }
 
+// IndexSession indexes the transcript at path and those of the subagents
+// its session started, as a hook names them, without scanning the projects
+// directory
+func (idx *Indexer) IndexSession(path string) error {
+	subagents, err := filepath.Glob(filepath.Join(strings.TrimSuffix(path, ".jsonl"), "subagents", "*.jsonl"))
+	if err != nil {
+		return fmt.Errorf("failed to find subagent transcripts: %w", err)
+	}
+	if err := idx.IndexPaths(append([]string{path}, subagents...)); err != nil {
+		return err
+	}
+
+	// Hooks are usually the only indexing that runs, so they also prune,
+	// though not on every call since that needs a scan
+	if _, err := idx.pruneIfDue(time.Now()); err != nil {
+		return fmt.Errorf("failed to prune conversations: %w", err)
+	}
+	return nil
+}
+
+// log reports the results of an indexing run
+func (s *IndexStats) log(elapsed time.Duration) {
+	log.Printf("Indexed %d messages from %d conversations (%d skipped, %d pruned) in %dms",
+		s.TotalIndexed, s.TotalConversations, s.TotalSkipped, s.TotalPruned, elapsed.Milliseconds())
+}
+
 // indexConversation indexes a single conversation file
 func (idx *Indexer) indexConversation(file ConversationFile) (indexed int, skipped bool, err error) {
 	// Get index state
@@ -88,152 +178,200 @@ func (idx *Indexer) indexConversation(file ConversationFile) (indexed int, skipp
 		return 0, false, fmt.Errorf("failed to get index state: %w", err)
 	}
 
-	// Check if file has been modified
-	lastModified := time.Unix(0, file.LastModified)
-	if state != nil && state.LastModifiedTime.Equal(lastModified) {
-		return 0, true, nil
-	}
-
-	// Read file lines
-	lines, err := idx.readLines(file.FilePath)
-	if err != nil {
-		return 0, false, fmt.Errorf("failed to read file: %w", err)
+	update, err := idx.parseConversation(file, state)
+	if err != nil || update == nil {
+		return 0, update == nil && err == nil, err
 	}
 
-	if len(lines) == 0 {
-		return 0, true, nil
+	if err := idx.db.SaveConversations([]db.ConversationUpdate{*update}); err != nil {
+		return 0, false, err
 	}
 
-	// Detect rollback: file has fewer lines than last indexed
-	if state != nil && len(lines) < state.LastIndexedLine {
-		log.Printf("Rollback detected for conversation %s (was %d lines, now %d lines)",
-			file.UUID, state.LastIndexedLine, len(lines))
-
-		// Clear this conversation's data
-		if err := idx.db.DeleteConversation(file.UUID); err != nil {
-			return 0, false, fmt.Errorf("failed to delete conversation: %w", err)
-		}
+	return len(update.Messages), update.Conversation == nil, nil
+}
 
-		if err := idx.db.DeleteIndexState(file.UUID); err != nil {
-			return 0, false, fmt.Errorf("failed to delete index state: %w", err)
-		}
+// unchanged reports whether a file is as it was when last indexed
+func unchanged(file ConversationFile, state *db.IndexState) bool {
+	return state != nil && state.LastModifiedTime.Equal(time.Unix(0, file.LastModified))
+}
 
-		// Re-index from scratch
-		state = nil
+// parseConversation reads the lines of a transcript added since state and
+// returns what indexing them writes, or nil if there is nothing to index. An
+// update without a Conversation only records a new modification time.
+// It doesn't touch the database, so transcripts can be parsed concurrently.
+func (idx *Indexer) parseConversation(file ConversationFile, state *db.IndexState) (*db.ConversationUpdate, error) {
+	// Check if file has been modified
+	lastModified := time.Unix(0, file.LastModified)
+	if unchanged(file, state) {
+		return nil, nil
 	}
 
-	// Determine starting line
-	startLine := 0
-	if state != nil && len(lines) >= state.LastIndexedLine {
-		startLine = state.LastIndexedLine
+	f, err := os.Open(file.FilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
+	defer f.Close()
 
-	if len(lines) <= startLine {
-		return 0, true, nil
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	// Get or create conversation record
-	var createdAt time.Time
-	var actualProjectPath string
-
-	if state == nil {
-		// Extract metadata from first line
-		firstTimestamp, err := idx.parser.GetTimestamp(lines[0])
-		if err == nil {
-			createdAt = firstTimestamp
-		} else {
-			createdAt = time.Now()
-		}
+	// Detect rollback: a transcript that was truncated, replaced or
+	// rewritten is read again from the start; entries it still has are
+	// updated in place and the rest are pruned once it has been parsed.
+	start, reason, err := resume(f, info, state)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
+	}
+	rollback := reason != ""
+	if rollback {
+		log.Printf("Reindexing conversation %s: its transcript %s", file.UUID, reason)
+		state = nil
+	}
 
-		actualCWD, err := idx.parser.GetCWD(lines[0])
-		if err == nil && actualCWD != "" {
-			actualProjectPath = actualCWD
-		} else {
-			actualProjectPath = file.ProjectPath
-		}
-	} else {
-		// Use existing values (would need to query from DB in full implementation)
-		createdAt = time.Now()
-		actualProjectPath = file.ProjectPath
+	// Only the modification time changed; record it so the transcript isn't
+	// read and checksummed again until it changes
+	if state != nil && info.Size() == state.FileSize {
+		touched := *state
+		touched.LastModifiedTime = lastModified
+		return &db.ConversationUpdate{State: &touched}, nil
 	}
 
-	// Save conversation record
-	conv := &db.Conversation{
-		UUID:         file.UUID,
-		ProjectPath:  actualProjectPath,
-		EncodedPath:  file.EncodedPath,
-		CreatedAt:    createdAt,
-		LastUpdated:  lastModified,
-		MessageCount: 0, // Will be updated by SaveMessages
+	if _, err := f.Seek(start.offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek to offset %d: %w", start.offset, err)
 	}
 
-	if err := idx.db.SaveConversation(conv); err != nil {
-		return 0, false, fmt.Errorf("failed to save conversation: %w", err)
+	// New conversations take their metadata from the first line that has
+	// it; summary entries at the top of a transcript have no timestamp or cwd.
+	// Existing ones keep their creation time, which saving never replaces,
+	// and their project path unless a new line records a cwd.
+	createdAt := time.Now()
+	actualProjectPath := file.ProjectPath
+	if state != nil {
+		actualProjectPath = ""
 	}
+	foundTimestamp, foundCWD := state != nil, false
 
-	// Index new lines
+	// Parse new lines, collecting the latest session metadata they record
 	var allMessages []db.Message
-	for i := startLine; i < len(lines); i++ {
-		messages, err := idx.parser.ParseLine(lines[i])
-		if err != nil {
+	var allToolCalls []db.ToolCall
+	var allEntries []db.Entry
+	var summaries []db.Summary
+	var session db.SessionMetadata
+	sidechain := false
+	end, err := readLines(io.LimitReader(f, info.Size()-start.offset), start, func(number int, line string) {
+		if !foundTimestamp {
+			if firstTimestamp, err := idx.parser.GetTimestamp(line); err == nil {
+				createdAt = firstTimestamp
+				foundTimestamp = true
+			}
+		}
+		if !foundCWD {
+			if actualCWD, err := idx.parser.GetCWD(line); err == nil && actualCWD != "" {
+				actualProjectPath = actualCWD
+				foundCWD = true
+			}
+		}
+
+		entry, err := idx.parser.ParseEntry(line)
+		if err != nil || entry == nil {
 			// Skip invalid lines
-			continue
+			return
+		}
+		session.Merge(entry.Session)
+		sidechain = sidechain || entry.Sidechain
+
+		if entry.UUID != "" {
+			allEntries = append(allEntries, db.Entry{
+				ConversationUUID: file.UUID,
+				UUID:             entry.UUID,
+				ParentUUID:       entry.ParentUUID,
+				Line:             number,
+				Sidechain:        entry.Sidechain,
+			})
 		}
 
-		for _, msg := range messages {
+		for part, msg := range entry.Messages {
 			msg.ConversationUUID = file.UUID
+			msg.Line = number
+			msg.EntryUUID = entry.UUID
+			msg.Part = part
+			msg.Sidechain = entry.Sidechain
+			msg.Content = idx.redactor.Redact(msg.Content)
+
+			// Summary entries title the conversation holding their leaf
+			if msg.Role == "title" {
+				msg.Timestamp = lastModified // Summary entries have no timestamp
+				summaries = append(summaries, db.Summary{LeafUUID: entry.LeafUUID, Title: msg})
+				continue
+			}
 			allMessages = append(allMessages, msg)
 		}
-	}
 
-	// Save messages in a batch
-	if len(allMessages) > 0 {
-		if err := idx.db.SaveMessages(allMessages); err != nil {
-			return 0, false, fmt.Errorf("failed to save messages: %w", err)
+		for _, call := range entry.ToolCalls {
+			call.ConversationUUID = file.UUID
+			call.Line = number
+			call.Command = idx.redactor.Redact(call.Command)
+			call.Pattern = idx.redactor.Redact(call.Pattern)
+			call.Prompt = idx.redactor.Redact(call.Prompt)
+			allToolCalls = append(allToolCalls, call)
 		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
-
-	// Update index state
-	newState := &db.IndexState{
-		ConversationUUID:  file.UUID,
-		LastIndexedLine:   len(lines),
-		LastModifiedTime:  lastModified,
+	checksum, err := prefixChecksum(f, end.offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file: %w", err)
 	}
 
-	if err := idx.db.UpdateIndexState(newState); err != nil {
-		return 0, false, fmt.Errorf("failed to update index state: %w", err)
+	// A transcript rolled back to nothing still prunes what was indexed
+	if end.line == start.line && !rollback {
+		return nil, nil
 	}
 
-	return len(allMessages), false, nil
-}
-
-// readLines reads all non-empty lines from a file
-func (idx *Indexer) readLines(path string) ([]string, error) {
-	file, err := os.Open(path)
-	if err != nil {
-		return nil, err
+	// Save conversation record
+	conv := &db.Conversation{
+		UUID:            file.UUID,
+		ProjectPath:     actualProjectPath,
+		EncodedPath:     file.EncodedPath,
+		CreatedAt:       createdAt,
+		LastUpdated:     lastModified,
+		MessageCount:    0, // Will be updated by SaveMessages
+		TranscriptPath:  file.FilePath,
+		SessionMetadata: session,
 	}
-	defer file.Close()
 
-	var lines []string
-	scanner := bufio.NewScanner(file)
-
-	// Increase buffer size to handle large JSONL lines (default is 64KB)
-	// Some assistant responses with code can exceed this
-	const maxCapacity = 10 * 1024 * 1024 // 10MB per line
-	buf := make([]byte, maxCapacity)
-	scanner.Buffer(buf, maxCapacity)
+	// A subagent's transcript is a sidechain of the session that started it
+	if sidechain && session.SessionID != "" && session.SessionID != file.UUID {
+		conv.ParentUUID = session.SessionID
+	}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line != "" {
-			lines = append(lines, line)
-		}
+	update := &db.ConversationUpdate{
+		Conversation: conv,
+		Prune:        rollback,
+		Entries:      allEntries,
+		Messages:     allMessages,
+		ToolCalls:    allToolCalls,
+		Summaries:    summaries,
+		State: &db.IndexState{
+			ConversationUUID: file.UUID,
+			LastIndexedLine:  end.line,
+			LastModifiedTime: lastModified,
+			ByteOffset:       end.offset,
+			FileSize:         info.Size(),
+			Inode:            inode(info),
+			PrefixChecksum:   checksum,
+		},
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, err
+	if rollback {
+		update.KeepEntries = make([]string, len(allEntries))
+		for i, entry := range allEntries {
+			update.KeepEntries[i] = entry.UUID
+		}
 	}
 
-	return lines, nil
+	return update, nil
 }
@@ -0,0 +1,112 @@
+package indexer
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/db"
+)
+
+// writeBenchTranscripts creates conversations of turns user/assistant pairs
+// spread over a few projects
+func writeBenchTranscripts(b *testing.B, dir string, conversations, turns int) {
+	b.Helper()
+	for c := 0; c < conversations; c++ {
+		project := filepath.Join(dir, fmt.Sprintf("-work-project-%d", c%5))
+		if err := os.MkdirAll(project, 0755); err != nil {
+			b.Fatalf("failed to create project directory: %v", err)
+		}
+
+		var lines []string
+		parent := "null"
+		for t := 0; t < turns; t++ {
+			user := fmt.Sprintf("u%d", t)
+			assistant := fmt.Sprintf("a%d", t)
+			lines = append(lines,
+				fmt.Sprintf(`{"type":"user","uuid":%q,"parentUuid":%s,"sessionId":"s%d","timestamp":"2026-01-05T10:%02d:00Z","cwd":"/work/project","message":{"content":"How should the webhook worker %d retry failed deliveries without flooding the queue?"}}`, user, parent, c, t%60, t),
+				fmt.Sprintf(`{"type":"assistant","uuid":%q,"parentUuid":%q,"sessionId":"s%d","timestamp":"2026-01-05T10:%02d:30Z","message":{"model":"claude-sonnet-4-5","content":[{"type":"text","text":"Use exponential backoff with jitter, capped at %d attempts, and park the rest in a dead letter queue."},{"type":"tool_use","name":"Edit","input":{"file_path":"/work/project/worker_%d.go","old_string":"a","new_string":"b"}}]}}`, assistant, user, c, t%60, t, t),
+			)
+			parent = fmt.Sprintf("%q", assistant)
+		}
+
+		path := filepath.Join(project, fmt.Sprintf("conv-%d.jsonl", c))
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+			b.Fatalf("failed to write transcript: %v", err)
+		}
+	}
+}
+
+// openBenchDB opens an empty database for one benchmark iteration
+func openBenchDB(b *testing.B) db.DB {
+	b.Helper()
+	database, err := db.Open(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatalf("failed to open database: %v", err)
+	}
+	if err := database.InitSchema(); err != nil {
+		b.Fatalf("failed to initialize schema: %v", err)
+	}
+	return database
+}
+
+// BenchmarkIndexAll compares a cold index of 200 transcripts saved one
+// conversation per transaction with the worker pool and batched writer
+func BenchmarkIndexAll(b *testing.B) {
+	projectsDir := b.TempDir()
+	writeBenchTranscripts(b, projectsDir, 200, 25)
+
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	b.Run("serial", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			b.StopTimer()
+			database := openBenchDB(b)
+			idx := NewIndexer(database, projectsDir)
+			files, err := idx.scanner.Scan()
+			if err != nil {
+				b.Fatalf("failed to scan: %v", err)
+			}
+			b.StartTimer()
+
+			for _, file := range files {
+				if _, _, err := idx.indexConversation(file); err != nil {
+					b.Fatalf("failed to index %s: %v", file.UUID, err)
+				}
+			}
+
+			b.StopTimer()
+			database.Close()
+			b.StartTimer()
+		}
+	})
+
+	jobCounts := []int{1, 4}
+	if n := runtime.NumCPU(); n > 4 {
+		jobCounts = append(jobCounts, n)
+	}
+	for _, jobs := range jobCounts {
+		b.Run(fmt.Sprintf("jobs=%d", jobs), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				database := openBenchDB(b)
+				idx := NewIndexerWithOptions(database, projectsDir, IndexerOptions{Jobs: jobs})
+				b.StartTimer()
+
+				if err := idx.IndexAll(false); err != nil {
+					b.Fatalf("failed to index: %v", err)
+				}
+
+				b.StopTimer()
+				database.Close()
+				b.StartTimer()
+			}
+		})
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package indexer
+
+import "os"
+
+// inode returns 0 where files have no inode numbers; replaced transcripts
+// are then only noticed if they are smaller than before
+func inode(info os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,17 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package indexer
+
+import (
+	"os"
+	"syscall"
+)
+
+// inode returns the inode number of a file, which changes when a transcript
+// is replaced rather than written in place
+func inode(info os.FileInfo) uint64 {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Ino)
+	}
+	return 0
+}
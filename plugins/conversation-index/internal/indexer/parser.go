@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/db"
 	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/shared"
@@ -12,27 +13,80 @@ import (
 
 // JSONLEntry represents a single line in a conversation JSONL file
 type JSONLEntry struct {
-	Type      string          `json:"type"`
-	Timestamp string          `json:"timestamp"`
-	CWD       string          `json:"cwd"`
-	Message   *JSONLMessage   `json:"message"`
+	UUID              string        `json:"uuid"`
+	ParentUUID        string        `json:"parentUuid"`
+	LogicalParentUUID string        `json:"logicalParentUuid"` // Parent across a compaction boundary
+	IsSidechain       bool          `json:"isSidechain"`       // Written by a subagent
+	Type              string        `json:"type"`
+	Timestamp         string        `json:"timestamp"`
+	CWD               string        `json:"cwd"`
+	SessionID         string        `json:"sessionId"`
+	GitBranch         string        `json:"gitBranch"`
+	Version           string        `json:"version"`
+	PermissionMode    string        `json:"permissionMode"`
+	Message           *JSONLMessage `json:"message"`
+	Summary           string        `json:"summary"`  // Title, on summary entries
+	LeafUUID          string        `json:"leafUuid"` // Last entry summarized, on summary entries
 }
 
 // JSONLMessage represents the message field in a JSONL entry
 type JSONLMessage struct {
+	Model   string      `json:"model"` // Set on assistant messages
 	Content interface{} `json:"content"`
 }
 
+// ParsedEntry is the tree position, searchable content, tool calls and
+// session metadata of a JSONL line
+type ParsedEntry struct {
+	UUID       string // Empty for lines outside the tree, such as summaries
+	ParentUUID string
+	LeafUUID   string // For a summary, the entry whose conversation it titles
+	Sidechain  bool
+	Messages   []db.Message
+	ToolCalls  []db.ToolCall
+	Session    db.SessionMetadata
+}
+
+// DefaultMaxToolResultBytes caps the text indexed per tool result
+const DefaultMaxToolResultBytes = 4096
+
+// ParserOptions selects optional content to index
+type ParserOptions struct {
+	ToolResults        bool // Index tool_result blocks under the tool_result role
+	MaxToolResultBytes int  // Cap on indexed text per tool result, 0 for DefaultMaxToolResultBytes
+	Thinking           bool // Index assistant thinking blocks under the thinking role
+}
+
 // Parser handles parsing of JSONL conversation files
-type Parser struct{}
+type Parser struct {
+	opts ParserOptions
+}
 
-// NewParser creates a new JSONL parser
+// NewParser creates a new JSONL parser with the default options
 func NewParser() *Parser {
-	return &Parser{}
+	return NewParserWithOptions(ParserOptions{})
+}
+
+// NewParserWithOptions creates a new JSONL parser
+func NewParserWithOptions(opts ParserOptions) *Parser {
+	if opts.MaxToolResultBytes <= 0 {
+		opts.MaxToolResultBytes = DefaultMaxToolResultBytes
+	}
+	return &Parser{opts: opts}
 }
 
 // ParseLine parses a single JSONL line and extracts searchable messages
 func (p *Parser) ParseLine(line string) ([]db.Message, error) {
+	parsed, err := p.ParseEntry(line)
+	if err != nil || parsed == nil {
+		return nil, err
+	}
+	return parsed.Messages, nil
+}
+
+// ParseEntry parses a single JSONL line and extracts searchable messages
+// along with the session metadata the entry records
+func (p *Parser) ParseEntry(line string) (*ParsedEntry, error) {
 	line = strings.TrimSpace(line)
 	if line == "" {
 		return nil, nil
@@ -43,7 +97,35 @@ func (p *Parser) ParseLine(line string) ([]db.Message, error) {
 		return nil, fmt.Errorf("failed to parse JSONL: %w", err)
 	}
 
-	return p.extractMessages(&entry)
+	messages, err := p.extractMessages(&entry)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := &ParsedEntry{
+		UUID:       entry.UUID,
+		ParentUUID: entry.ParentUUID,
+		LeafUUID:   entry.LeafUUID,
+		Sidechain:  entry.IsSidechain,
+		Messages:   messages,
+		ToolCalls:  p.extractToolCalls(&entry),
+		Session: db.SessionMetadata{
+			SessionID:      entry.SessionID,
+			GitBranch:      entry.GitBranch,
+			ClaudeVersion:  entry.Version,
+			PermissionMode: entry.PermissionMode,
+		},
+	}
+	if entry.Message != nil {
+		parsed.Session.Model = entry.Message.Model
+	}
+
+	// Compaction starts a new root that continues the old branch
+	if parsed.ParentUUID == "" {
+		parsed.ParentUUID = entry.LogicalParentUUID
+	}
+
+	return parsed, nil
 }
 
 // extractMessages extracts searchable content from a JSONL entry
@@ -64,14 +146,31 @@ func (p *Parser) extractMessages(entry *JSONLEntry) ([]db.Message, error) {
 				Content:   content,
 			})
 		}
+
+		// Tool output comes back in user entries as tool_result blocks
+		if p.opts.ToolResults {
+			messages = append(messages, p.extractToolResults(entry.Message.Content, timestamp)...)
+		}
 	}
 
 	// Handle assistant messages
 	if entry.Type == "assistant" && entry.Message != nil {
 		msgs := p.extractAssistantMessages(entry.Message.Content, timestamp)
+		for i := range msgs {
+			msgs[i].Model = entry.Message.Model
+		}
 		messages = append(messages, msgs...)
 	}
 
+	// Handle summary entries, which carry the conversation title
+	if entry.Type == "summary" && entry.Summary != "" {
+		messages = append(messages, db.Message{
+			Timestamp: timestamp,
+			Role:      "title",
+			Content:   entry.Summary,
+		})
+	}
+
 	return messages, nil
 }
 
@@ -104,6 +203,17 @@ func (p *Parser) extractAssistantMessages(content interface{}, timestamp time.Ti
 			}
 		}
 
+		// Extract reasoning, when enabled
+		if itemType == "thinking" && p.opts.Thinking {
+			if thinking, ok := itemMap["thinking"].(string); ok && strings.TrimSpace(thinking) != "" {
+				messages = append(messages, db.Message{
+					Timestamp: timestamp,
+					Role:      "thinking",
+					Content:   thinking,
+				})
+			}
+		}
+
 		// Extract tool use information
 		if itemType == "tool_use" {
 			toolMsg := p.extractToolUse(itemMap, timestamp)
@@ -151,6 +261,127 @@ func (p *Parser) extractToolUse(itemMap map[string]interface{}, timestamp time.T
 	}
 }
 
+// extractToolResults extracts the text of tool_result blocks, capped at
+// MaxToolResultBytes each
+func (p *Parser) extractToolResults(content interface{}, timestamp time.Time) []db.Message {
+	var messages []db.Message
+
+	contentArray, ok := content.([]interface{})
+	if !ok {
+		return messages
+	}
+
+	for _, item := range contentArray {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok || itemMap["type"] != "tool_result" {
+			continue
+		}
+
+		text := strings.TrimSpace(toolResultText(itemMap["content"]))
+		if text == "" {
+			continue
+		}
+
+		isError, _ := itemMap["is_error"].(bool)
+		messages = append(messages, db.Message{
+			Timestamp: timestamp,
+			Role:      "tool_result",
+			Content:   truncateBytes(text, p.opts.MaxToolResultBytes),
+			IsError:   isError,
+		})
+	}
+
+	return messages
+}
+
+// toolResultText joins the text of a tool_result's content, which is either
+// a string or an array of blocks of which only text blocks are kept
+func toolResultText(content interface{}) string {
+	if text, ok := content.(string); ok {
+		return text
+	}
+
+	blocks, ok := content.([]interface{})
+	if !ok {
+		return ""
+	}
+
+	var parts []string
+	for _, block := range blocks {
+		blockMap, ok := block.(map[string]interface{})
+		if !ok || blockMap["type"] != "text" {
+			continue
+		}
+		if text, ok := blockMap["text"].(string); ok && text != "" {
+			parts = append(parts, text)
+		}
+	}
+	return strings.Join(parts, "\n")
+}
+
+// truncateBytes cuts s to at most maxBytes without splitting a UTF-8
+// character, marking the cut with "..."
+func truncateBytes(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	cut := maxBytes
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + "..."
+}
+
+// extractToolCalls extracts the structured tool calls of an assistant entry
+func (p *Parser) extractToolCalls(entry *JSONLEntry) []db.ToolCall {
+	if entry.Type != "assistant" || entry.Message == nil {
+		return nil
+	}
+
+	contentArray, ok := entry.Message.Content.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	timestamp, err := shared.ParseTimestamp(entry.Timestamp)
+	if err != nil {
+		timestamp = time.Now() // Fallback to current time
+	}
+
+	var calls []db.ToolCall
+	for _, item := range contentArray {
+		itemMap, ok := item.(map[string]interface{})
+		if !ok || itemMap["type"] != "tool_use" {
+			continue
+		}
+
+		call := db.ToolCall{Timestamp: timestamp}
+		call.Tool, _ = itemMap["name"].(string)
+		if call.Tool == "" {
+			continue
+		}
+
+		if input, ok := itemMap["input"].(map[string]interface{}); ok {
+			call.FilePath, _ = input["file_path"].(string)
+			if call.FilePath == "" {
+				call.FilePath, _ = input["notebook_path"].(string)
+			}
+			call.Command, _ = input["command"].(string)
+			call.Pattern, _ = input["pattern"].(string)
+
+			// Task calls launch a subagent, whose sidechain starts with the prompt
+			call.SubagentType, _ = input["subagent_type"].(string)
+			if call.SubagentType != "" {
+				call.Prompt, _ = input["prompt"].(string)
+			}
+		}
+
+		calls = append(calls, call)
+	}
+
+	return calls
+}
+
 // GetCWD extracts the working directory from the first JSONL line
 func (p *Parser) GetCWD(firstLine string) (string, error) {
 	var entry JSONLEntry
@@ -2,6 +2,8 @@ package indexer
 
 import (
 	"testing"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/db"
 )
 
 func TestParser_ParseLine_UserMessage(t *testing.T) {
@@ -102,6 +104,222 @@ func TestParser_ParseLine_MultipleContent(t *testing.T) {
 	}
 }
 
+func TestParser_ParseLine_Summary(t *testing.T) {
+	parser := NewParser()
+
+	input := `{"type":"summary","summary":"Fix webhook retry backoff","leafUuid":"abc-123"}`
+
+	messages, err := parser.ParseLine(input)
+	if err != nil {
+		t.Fatalf("failed to parse line: %v", err)
+	}
+
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(messages))
+	}
+
+	if messages[0].Role != "title" {
+		t.Errorf("expected role 'title', got %q", messages[0].Role)
+	}
+
+	if messages[0].Content != "Fix webhook retry backoff" {
+		t.Errorf("expected content 'Fix webhook retry backoff', got %q", messages[0].Content)
+	}
+}
+
+func TestParser_ParseEntry_SessionMetadata(t *testing.T) {
+	parser := NewParser()
+
+	input := `{"type":"assistant","timestamp":"2026-01-05T15:32:32.836Z","sessionId":"sess-1","gitBranch":"feature/x","version":"2.0.14","permissionMode":"plan","message":{"model":"claude-opus-4-1","content":[{"type":"text","text":"Done"},{"type":"tool_use","name":"Bash","input":{"command":"ls"}}]}}`
+
+	entry, err := parser.ParseEntry(input)
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+
+	want := db.SessionMetadata{
+		SessionID:      "sess-1",
+		GitBranch:      "feature/x",
+		ClaudeVersion:  "2.0.14",
+		Model:          "claude-opus-4-1",
+		PermissionMode: "plan",
+	}
+	if entry.Session != want {
+		t.Errorf("expected session %+v, got %+v", want, entry.Session)
+	}
+
+	if len(entry.Messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(entry.Messages))
+	}
+	for _, msg := range entry.Messages {
+		if msg.Model != "claude-opus-4-1" {
+			t.Errorf("expected %s message model claude-opus-4-1, got %q", msg.Role, msg.Model)
+		}
+	}
+}
+
+func TestParser_ParseEntry_ToolCalls(t *testing.T) {
+	parser := NewParser()
+
+	input := `{"type":"assistant","timestamp":"2026-01-05T15:32:32.836Z","message":{"content":[` +
+		`{"type":"text","text":"Looking"},` +
+		`{"type":"tool_use","name":"Edit","input":{"file_path":"/src/main.go","old_string":"a","new_string":"b"}},` +
+		`{"type":"tool_use","name":"Bash","input":{"command":"go test ./...","description":"Run tests"}},` +
+		`{"type":"tool_use","name":"Grep","input":{"pattern":"TODO","path":"/src"}},` +
+		`{"type":"tool_use","name":"NotebookEdit","input":{"notebook_path":"/src/a.ipynb"}}]}}`
+
+	entry, err := parser.ParseEntry(input)
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+
+	want := []db.ToolCall{
+		{Tool: "Edit", FilePath: "/src/main.go"},
+		{Tool: "Bash", Command: "go test ./..."},
+		{Tool: "Grep", Pattern: "TODO"},
+		{Tool: "NotebookEdit", FilePath: "/src/a.ipynb"},
+	}
+	if len(entry.ToolCalls) != len(want) {
+		t.Fatalf("expected %d tool calls, got %d", len(want), len(entry.ToolCalls))
+	}
+	for i, call := range entry.ToolCalls {
+		if call.Timestamp.IsZero() {
+			t.Errorf("expected timestamp on %s call", call.Tool)
+		}
+		call.Timestamp = want[i].Timestamp
+		if call != want[i] {
+			t.Errorf("expected tool call %+v, got %+v", want[i], call)
+		}
+	}
+
+	// Flattened tool messages are still indexed for search
+	if len(entry.Messages) != 5 {
+		t.Errorf("expected 5 messages, got %d", len(entry.Messages))
+	}
+}
+
+func TestParser_ParseLine_ToolResults(t *testing.T) {
+	input := `{"type":"user","timestamp":"2026-01-05T15:32:32.836Z","message":{"role":"user","content":[` +
+		`{"type":"tool_result","tool_use_id":"t1","content":"exit status 2\nundefined: parseQuery","is_error":true},` +
+		`{"type":"tool_result","tool_use_id":"t2","content":[{"type":"text","text":"ok"},{"type":"image"},{"type":"text","text":"done"}]},` +
+		`{"type":"tool_result","tool_use_id":"t3","content":""}]}}`
+
+	// Tool results are only indexed when enabled
+	messages, err := NewParser().ParseLine(input)
+	if err != nil {
+		t.Fatalf("failed to parse line: %v", err)
+	}
+	if len(messages) != 0 {
+		t.Errorf("expected no messages by default, got %d", len(messages))
+	}
+
+	parser := NewParserWithOptions(ParserOptions{ToolResults: true})
+	messages, err = parser.ParseLine(input)
+	if err != nil {
+		t.Fatalf("failed to parse line: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+
+	if messages[0].Role != "tool_result" || !messages[0].IsError || messages[0].Content != "exit status 2\nundefined: parseQuery" {
+		t.Errorf("unexpected error result: %+v", messages[0])
+	}
+	if messages[1].IsError || messages[1].Content != "ok\ndone" {
+		t.Errorf("unexpected text block result: %+v", messages[1])
+	}
+}
+
+func TestParser_ParseLine_ToolResultCap(t *testing.T) {
+	parser := NewParserWithOptions(ParserOptions{ToolResults: true, MaxToolResultBytes: 4})
+
+	// "é" takes bytes 4 and 5, so a 4-byte cut must not split it
+	input := `{"type":"user","message":{"content":[{"type":"tool_result","content":"aaaéllo"}]}}`
+	messages, err := parser.ParseLine(input)
+	if err != nil {
+		t.Fatalf("failed to parse line: %v", err)
+	}
+	if len(messages) != 1 || messages[0].Content != "aaa..." {
+		t.Errorf("expected capped content %q, got %+v", "aaa...", messages)
+	}
+}
+
+func TestParser_ParseLine_Thinking(t *testing.T) {
+	input := `{"type":"assistant","timestamp":"2026-01-05T15:32:32.836Z","message":{"model":"claude-opus-4-1","content":[` +
+		`{"type":"thinking","thinking":"The retry loop never resets its backoff","signature":"sig"},` +
+		`{"type":"text","text":"Found it"}]}}`
+
+	// Thinking is only indexed when enabled
+	messages, err := NewParser().ParseLine(input)
+	if err != nil {
+		t.Fatalf("failed to parse line: %v", err)
+	}
+	if len(messages) != 1 || messages[0].Role != "assistant" {
+		t.Errorf("expected only the assistant text by default, got %+v", messages)
+	}
+
+	messages, err = NewParserWithOptions(ParserOptions{Thinking: true}).ParseLine(input)
+	if err != nil {
+		t.Fatalf("failed to parse line: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(messages))
+	}
+	if messages[0].Role != "thinking" || messages[0].Content != "The retry loop never resets its backoff" {
+		t.Errorf("unexpected thinking message: %+v", messages[0])
+	}
+	if messages[0].Model != "claude-opus-4-1" {
+		t.Errorf("expected model on thinking message, got %q", messages[0].Model)
+	}
+}
+
+func TestParser_ParseEntry_TreePosition(t *testing.T) {
+	parser := NewParser()
+
+	entry, err := parser.ParseEntry(`{"type":"user","uuid":"u2","parentUuid":"a1","message":{"content":"Hi"}}`)
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	if entry.UUID != "u2" || entry.ParentUUID != "a1" {
+		t.Errorf("expected u2 under a1, got %q under %q", entry.UUID, entry.ParentUUID)
+	}
+
+	// A compaction boundary links to the branch it continues
+	entry, err = parser.ParseEntry(`{"type":"system","subtype":"compact_boundary","uuid":"c1","parentUuid":null,"logicalParentUuid":"a9"}`)
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	if entry.UUID != "c1" || entry.ParentUUID != "a9" {
+		t.Errorf("expected c1 under a9, got %q under %q", entry.UUID, entry.ParentUUID)
+	}
+}
+
+func TestParser_ParseEntry_Sidechain(t *testing.T) {
+	parser := NewParser()
+
+	entry, err := parser.ParseEntry(`{"type":"user","uuid":"s1","isSidechain":true,"sessionId":"main","message":{"content":"Find the config"}}`)
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	if !entry.Sidechain || entry.Session.SessionID != "main" {
+		t.Errorf("expected a sidechain entry of session main, got %+v", entry)
+	}
+
+	input := `{"type":"assistant","message":{"content":[` +
+		`{"type":"tool_use","name":"Task","input":{"description":"Explore","subagent_type":"Explore","prompt":"Find the config"}}]}}`
+	entry, err = parser.ParseEntry(input)
+	if err != nil {
+		t.Fatalf("failed to parse entry: %v", err)
+	}
+	if len(entry.ToolCalls) != 1 {
+		t.Fatalf("expected 1 tool call, got %d", len(entry.ToolCalls))
+	}
+	call := entry.ToolCalls[0]
+	if call.Tool != "Task" || call.SubagentType != "Explore" || call.Prompt != "Find the config" {
+		t.Errorf("expected an Explore Task call with its prompt, got %+v", call)
+	}
+}
+
 func TestParser_ParseLine_EmptyLine(t *testing.T) {
 	parser := NewParser()
 
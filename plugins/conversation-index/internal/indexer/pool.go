@@ -0,0 +1,133 @@
+package indexer
+
+import (
+	"log"
+	"runtime"
+	"sync"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/db"
+)
+
+// A write batch is committed once it holds this many messages or
+// conversations, whichever comes first
+const (
+	batchMessages      = 10000
+	batchConversations = 500
+)
+
+// parsed is a worker's result for one transcript; update is nil when there
+// was nothing new to index
+type parsed struct {
+	file   ConversationFile
+	update *db.ConversationUpdate
+	err    error
+}
+
+// indexFiles indexes files with a pool of workers that read and parse
+// transcripts, feeding a single writer that saves them in large
+// transactions. Index states are read before the workers start, so only the
+// writer uses the database.
+func (idx *Indexer) indexFiles(files []ConversationFile, stats *IndexStats) {
+	states := make(map[string]*db.IndexState, len(files))
+	var pending []ConversationFile
+	for _, file := range files {
+		state, err := idx.db.GetIndexState(file.UUID)
+		if err != nil {
+			log.Printf("Failed to index conversation %s: failed to get index state: %v", file.UUID, err)
+			continue
+		}
+		stats.TotalConversations++
+		if unchanged(file, state) {
+			stats.TotalSkipped++
+			continue
+		}
+		states[file.UUID] = state
+		pending = append(pending, file)
+	}
+
+	jobs := idx.jobs
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+
+	work := make(chan ConversationFile)
+	results := make(chan parsed, jobs)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range work {
+				update, err := idx.parseConversation(file, states[file.UUID])
+				results <- parsed{file: file, update: update, err: err}
+			}
+		}()
+	}
+	go func() {
+		for _, file := range pending {
+			work <- file
+		}
+		close(work)
+		wg.Wait()
+		close(results)
+	}()
+
+	w := &batchWriter{db: idx.db, stats: stats}
+	for result := range results {
+		switch {
+		case result.err != nil:
+			log.Printf("Failed to index conversation %s: %v", result.file.UUID, result.err)
+			stats.TotalConversations--
+		case result.update == nil:
+			stats.TotalSkipped++
+		default:
+			// Recording a new modification time still counts as a skip
+			if result.update.Conversation == nil {
+				stats.TotalSkipped++
+			}
+			w.add(*result.update)
+		}
+	}
+	w.flush()
+}
+
+// batchWriter collects conversation updates and saves them in batches
+type batchWriter struct {
+	db       db.DB
+	stats    *IndexStats
+	updates  []db.ConversationUpdate
+	messages int
+}
+
+// add queues an update, saving the batch once it is full
+func (w *batchWriter) add(update db.ConversationUpdate) {
+	w.updates = append(w.updates, update)
+	w.messages += len(update.Messages)
+	if w.messages >= batchMessages || len(w.updates) >= batchConversations {
+		w.flush()
+	}
+}
+
+// flush saves the queued updates in one transaction. If that fails they are
+// saved one at a time, so one bad transcript doesn't lose the whole batch.
+func (w *batchWriter) flush() {
+	if len(w.updates) == 0 {
+		return
+	}
+
+	if err := w.db.SaveConversations(w.updates); err == nil {
+		w.stats.TotalIndexed += w.messages
+	} else {
+		for _, update := range w.updates {
+			if err := w.db.SaveConversations([]db.ConversationUpdate{update}); err != nil {
+				log.Printf("Failed to index conversation %s: %v", update.State.ConversationUUID, err)
+				w.stats.TotalConversations--
+				continue
+			}
+			w.stats.TotalIndexed += len(update.Messages)
+		}
+	}
+
+	w.updates = nil
+	w.messages = 0
+}
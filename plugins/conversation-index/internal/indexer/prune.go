@@ -0,0 +1,129 @@
+package indexer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/shared"
+)
+
+// RetentionPolicy limits how long conversations stay in the index, by the
+// time their transcript was last modified
+type RetentionPolicy struct {
+	MaxAge   time.Duration            // 0 keeps conversations forever
+	Projects map[string]time.Duration // MaxAge overrides by project path; 0 keeps forever
+}
+
+// maxAge returns the retention period for a project, matching overrides
+// against either its path or its encoded directory name
+func (p RetentionPolicy) maxAge(projectPath, encodedPath string) time.Duration {
+	if age, ok := p.Projects[projectPath]; ok {
+		return age
+	}
+	for path, age := range p.Projects {
+		if shared.EncodeProjectPath(path) == encodedPath {
+			return age
+		}
+	}
+	return p.MaxAge
+}
+
+// expired reports whether a conversation last updated at updated is past
+// its project's retention period
+func (p RetentionPolicy) expired(projectPath, encodedPath string, updated, now time.Time) bool {
+	age := p.maxAge(projectPath, encodedPath)
+	return age > 0 && updated.Before(now.Add(-age))
+}
+
+// pruneConversations removes conversations whose transcript no longer
+// exists and those past the retention policy. present holds the
+// conversations the scan found.
+func (idx *Indexer) pruneConversations(present map[string]bool, now time.Time) (int, error) {
+	conversations, err := idx.db.ListConversations()
+	if err != nil {
+		return 0, err
+	}
+
+	pruned := 0
+	for _, conv := range conversations {
+		var reason string
+		switch {
+		case !present[conv.UUID] && transcriptMissing(conv.TranscriptPath):
+			reason = "transcript deleted"
+		case idx.retention.expired(conv.ProjectPath, conv.EncodedPath, conv.LastUpdated, now):
+			reason = "past retention"
+		default:
+			continue
+		}
+
+		if err := idx.db.RemoveConversation(conv.UUID); err != nil {
+			return pruned, fmt.Errorf("failed to remove conversation %s: %w", conv.UUID, err)
+		}
+		log.Printf("Pruned conversation %s (%s)", conv.UUID, reason)
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+// pruneInterval is how often IndexSession prunes deleted and expired
+// conversations
+const pruneInterval = 24 * time.Hour
+
+// pruneIfDue scans for transcripts and prunes conversations as IndexAll
+// does, if it hasn't been done for pruneInterval
+func (idx *Indexer) pruneIfDue(now time.Time) (int, error) {
+	last, err := idx.db.LastPruned()
+	if err != nil {
+		return 0, err
+	}
+	if now.Sub(last) < pruneInterval {
+		return 0, nil
+	}
+
+	files, err := idx.scanner.Scan()
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan conversations: %w", err)
+	}
+	present := make(map[string]bool, len(files))
+	for _, file := range files {
+		present[file.UUID] = true
+	}
+
+	pruned, err := idx.pruneConversations(present, now)
+	if err != nil {
+		return pruned, err
+	}
+	return pruned, idx.db.SetLastPruned(now)
+}
+
+// removeTranscript removes the conversation of a deleted transcript,
+// reporting whether it had been indexed
+func (idx *Indexer) removeTranscript(path string) (bool, error) {
+	uuid := strings.TrimSuffix(filepath.Base(path), ".jsonl")
+	state, err := idx.db.GetIndexState(uuid)
+	if err != nil || state == nil {
+		return false, err
+	}
+
+	if err := idx.db.RemoveConversation(uuid); err != nil {
+		return false, fmt.Errorf("failed to remove conversation %s: %w", uuid, err)
+	}
+	log.Printf("Pruned conversation %s (transcript deleted)", uuid)
+	return true, nil
+}
+
+// transcriptMissing reports whether a transcript is known to be gone.
+// Conversations indexed before transcript paths were recorded only count
+// as missing when the scan didn't find them.
+func transcriptMissing(path string) bool {
+	if path == "" {
+		return true
+	}
+	_, err := os.Stat(path)
+	return os.IsNotExist(err)
+}
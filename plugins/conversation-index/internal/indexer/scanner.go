@@ -74,27 +74,64 @@ func (s *Scanner) scanProject(projectDir, decodedPath, encodedPath string) ([]Co
 	var files []ConversationFile
 
 	for _, entry := range entries {
-		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".jsonl") {
+		if entry.IsDir() {
+			// Subagent transcripts can live under <session>/subagents
+			subagents, err := s.scanProject(filepath.Join(projectDir, entry.Name(), "subagents"), decodedPath, encodedPath)
+			if err == nil {
+				files = append(files, subagents...)
+			}
+			continue
+		}
+		if !strings.HasSuffix(entry.Name(), ".jsonl") {
 			continue
 		}
 
-		uuid := strings.TrimSuffix(entry.Name(), ".jsonl")
-		filePath := filepath.Join(projectDir, entry.Name())
-
-		// Get file modification time
-		info, err := os.Stat(filePath)
+		file, err := conversationFile(filepath.Join(projectDir, entry.Name()), decodedPath, encodedPath)
 		if err != nil {
 			continue
 		}
-
-		files = append(files, ConversationFile{
-			UUID:         uuid,
-			FilePath:     filePath,
-			ProjectPath:  decodedPath,
-			EncodedPath:  encodedPath,
-			LastModified: info.ModTime().UnixNano(),
-		})
+		files = append(files, file)
 	}
 
 	return files, nil
 }
+
+// Lookup returns the conversation file for a transcript at a path Scan
+// would find it at. The error satisfies os.IsNotExist if it was deleted.
+func (s *Scanner) Lookup(path string) (ConversationFile, error) {
+	encodedPath, ok := s.projectOf(path)
+	if !ok {
+		return ConversationFile{}, fmt.Errorf("not a transcript: %s", path)
+	}
+	return conversationFile(path, shared.DecodeProjectPath(encodedPath), encodedPath)
+}
+
+// projectOf returns the encoded project directory of a transcript path:
+// <project>/<uuid>.jsonl or <project>/<session>/subagents/<uuid>.jsonl
+func (s *Scanner) projectOf(path string) (string, bool) {
+	rel, err := filepath.Rel(s.projectsDir, path)
+	if err != nil || !strings.HasSuffix(rel, ".jsonl") {
+		return "", false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) == 2 || (len(parts) == 4 && parts[2] == "subagents") {
+		return parts[0], parts[0] != ".."
+	}
+	return "", false
+}
+
+// conversationFile stats a transcript for its modification time
+func conversationFile(filePath, decodedPath, encodedPath string) (ConversationFile, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return ConversationFile{}, err
+	}
+
+	return ConversationFile{
+		UUID:         strings.TrimSuffix(filepath.Base(filePath), ".jsonl"),
+		FilePath:     filePath,
+		ProjectPath:  decodedPath,
+		EncodedPath:  encodedPath,
+		LastModified: info.ModTime().UnixNano(),
+	}, nil
+}
@@ -0,0 +1,97 @@
+package indexer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"hash/crc32"
+	"io"
+	"os"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/db"
+)
+
+// checksumTable is for CRC-32C, which most CPUs compute in hardware
+var checksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// checksumWindow is how much of the start, and of the end, of a
+// transcript's indexed prefix is checksummed
+const checksumWindow = 64 << 10
+
+// position is how far into a transcript indexing has read
+type position struct {
+	offset int64 // Just past the last line read
+	line   int   // Number of lines read, blank ones included
+}
+
+// resume returns the position to continue reading a transcript from. If the
+// transcript must be read again from the start, it also returns why: it
+// shrank, was replaced or was rewritten since it was indexed, or was indexed
+// before offsets were recorded.
+func resume(f *os.File, info os.FileInfo, state *db.IndexState) (position, string, error) {
+	switch {
+	case state == nil:
+		return position{}, "", nil
+	case state.ByteOffset == 0 && state.LastIndexedLine > 0:
+		return position{}, "was indexed before byte offsets were recorded", nil
+	case info.Size() < state.FileSize:
+		return position{}, "shrank", nil
+	case state.Inode != 0 && inode(info) != 0 && inode(info) != state.Inode:
+		return position{}, "was replaced", nil
+	}
+
+	checksum, err := prefixChecksum(f, state.ByteOffset)
+	if err != nil {
+		return position{}, "", err
+	}
+	if checksum != state.PrefixChecksum {
+		return position{}, "was rewritten", nil
+	}
+
+	return position{offset: state.ByteOffset, line: state.LastIndexedLine}, "", nil
+}
+
+// prefixChecksum returns the CRC-32C of the first and last checksumWindow
+// bytes of the first n bytes of f, so checking it takes the same time however
+// long a transcript grows. Compaction and restores rewrite the start or the
+// end; an edit in the middle of a long transcript goes unnoticed.
+func prefixChecksum(f io.ReaderAt, n int64) (uint32, error) {
+	h := crc32.New(checksumTable)
+	head := min(n, checksumWindow)
+	if _, err := io.Copy(h, io.NewSectionReader(f, 0, head)); err != nil {
+		return 0, err
+	}
+	tail := max(head, n-checksumWindow)
+	if _, err := io.Copy(h, io.NewSectionReader(f, tail, n-tail)); err != nil {
+		return 0, err
+	}
+	return h.Sum32(), nil
+}
+
+// readLines streams r, which starts at pos, calling fn with each non-empty
+// line and its line number in the file; blank lines are counted but skipped.
+// A last line without a line break is only taken if it is complete JSON, so
+// one still being written is left for the next run. It returns the position
+// after the last line it took.
+func readLines(r io.Reader, pos position, fn func(number int, text string)) (position, error) {
+	reader := bufio.NewReader(r)
+	for {
+		chunk, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return pos, err
+		}
+		text := bytes.TrimSpace(chunk)
+		if err == io.EOF && (len(text) == 0 || !json.Valid(text)) {
+			return pos, nil
+		}
+
+		pos.offset += int64(len(chunk))
+		pos.line++
+		if len(text) > 0 {
+			fn(pos.line, string(text))
+		}
+		if err == io.EOF {
+			return pos, nil
+		}
+	}
+}
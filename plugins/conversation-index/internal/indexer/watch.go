@@ -0,0 +1,184 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher reports transcripts that change under the projects directory.
+// Directories are watched down to <project>/<session>/subagents, the
+// deepest place transcripts are written.
+type Watcher struct {
+	scanner *Scanner
+	delay   time.Duration
+	watcher *fsnotify.Watcher
+	pending map[string]bool
+}
+
+// NewWatcher starts watching projectsDir. Changes are reported at most once
+// per delay, so a burst of writes to a transcript is indexed once.
+func NewWatcher(projectsDir string, delay time.Duration) (*Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create watcher: %w", err)
+	}
+
+	w := &Watcher{
+		scanner: NewScanner(projectsDir),
+		delay:   delay,
+		watcher: watcher,
+		pending: make(map[string]bool),
+	}
+	if err := w.watch(projectsDir); err != nil {
+		watcher.Close()
+		return nil, err
+	}
+	clear(w.pending) // Anything already there is for the caller to index
+
+	return w, nil
+}
+
+// Close stops watching
+func (w *Watcher) Close() error {
+	return w.watcher.Close()
+}
+
+// Run calls changed with the transcripts written, created or deleted since
+// the last call until ctx is done, then once more with any still pending.
+// If changed fails, its transcripts are passed again on the next call.
+func (w *Watcher) Run(ctx context.Context, changed func(paths []string) error) error {
+	timer := time.NewTimer(w.delay)
+	timer.Stop()
+	armed := false
+
+	flush := func() {
+		armed = false
+		if len(w.pending) == 0 {
+			return
+		}
+		paths := make([]string, 0, len(w.pending))
+		for path := range w.pending {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+
+		if err := changed(paths); err != nil {
+			log.Printf("Failed to index changed transcripts: %v", err)
+			return
+		}
+		clear(w.pending)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			flush()
+			return nil
+
+		case <-timer.C:
+			flush()
+
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return nil
+			}
+			w.handle(event)
+
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return nil
+			}
+			if !errors.Is(err, fsnotify.ErrEventOverflow) {
+				log.Printf("Watch error: %v", err)
+				continue
+			}
+			// Events were dropped, so any transcript may have changed
+			log.Printf("Watch events overflowed, rescanning %s", w.scanner.projectsDir)
+			if err := w.watch(w.scanner.projectsDir); err != nil {
+				log.Printf("Failed to rescan: %v", err)
+			}
+		}
+
+		// Coalesce everything that arrives until the timer fires
+		if len(w.pending) > 0 && !armed {
+			timer.Reset(w.delay)
+			armed = true
+		}
+	}
+}
+
+// handle records the transcript an event is for, and watches new
+// directories that can hold transcripts
+func (w *Watcher) handle(event fsnotify.Event) {
+	if event.Has(fsnotify.Create) {
+		if info, err := os.Lstat(event.Name); err == nil && info.IsDir() {
+			if err := w.watch(event.Name); err != nil {
+				log.Printf("Failed to watch %s: %v", event.Name, err)
+			}
+			return
+		}
+	}
+
+	if !event.Has(fsnotify.Create | fsnotify.Write | fsnotify.Remove | fsnotify.Rename) {
+		return
+	}
+	if _, ok := w.scanner.projectOf(event.Name); ok {
+		w.pending[event.Name] = true
+	}
+}
+
+// watch adds watches on dir and the directories under it that can hold
+// transcripts. Transcripts already in them are marked pending, since they
+// may have been written before the watch was in place.
+func (w *Watcher) watch(dir string) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Directories can disappear while being walked
+			if path == dir {
+				return err
+			}
+			return nil
+		}
+
+		if !d.IsDir() {
+			if _, ok := w.scanner.projectOf(path); ok {
+				w.pending[path] = true
+			}
+			return nil
+		}
+
+		if !w.watchable(path) {
+			return filepath.SkipDir
+		}
+		if err := w.watcher.Add(path); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+// watchable reports whether dir is the projects directory or one under it
+// that transcripts or their subagent directories are created in
+func (w *Watcher) watchable(dir string) bool {
+	rel, err := filepath.Rel(w.scanner.projectsDir, dir)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	if rel == "." {
+		return true
+	}
+
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	return len(parts) <= 2 || (len(parts) == 3 && parts[2] == "subagents")
+}
@@ -0,0 +1,132 @@
+package indexer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/db"
+)
+
+func TestWatcher(t *testing.T) {
+	tmpDir := t.TempDir()
+	project := filepath.Join(tmpDir, "-work-app")
+	if err := os.MkdirAll(project, 0755); err != nil {
+		t.Fatalf("failed to create project directory: %v", err)
+	}
+	existing := filepath.Join(project, "existing.jsonl")
+	if err := os.WriteFile(existing, []byte("{}\n"), 0644); err != nil {
+		t.Fatalf("failed to create test file: %v", err)
+	}
+
+	watcher, err := NewWatcher(tmpDir, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("failed to create watcher: %v", err)
+	}
+	defer watcher.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	batches := make(chan []string, 10)
+	done := make(chan error)
+	go func() {
+		done <- watcher.Run(ctx, func(paths []string) error {
+			batches <- paths
+			return nil
+		})
+	}()
+
+	next := func() []string {
+		t.Helper()
+		select {
+		case paths := <-batches:
+			return paths
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for changes")
+			return nil
+		}
+	}
+	write := func(path string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", path, err)
+		}
+	}
+
+	// A burst of writes is reported once; other files are ignored
+	for i := 0; i < 5; i++ {
+		write(existing)
+	}
+	write(filepath.Join(project, "notes.txt"))
+	if got, want := next(), []string{existing}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// Transcripts in new project and subagent directories are picked up
+	subagents := filepath.Join(tmpDir, "-work-new", "session", "subagents")
+	if err := os.MkdirAll(subagents, 0755); err != nil {
+		t.Fatalf("failed to create subagents directory: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond) // Let the new directories be watched
+	agent := filepath.Join(subagents, "agent-1.jsonl")
+	write(agent)
+	if got := next(); !reflect.DeepEqual(got, []string{agent}) {
+		t.Errorf("expected %s, got %v", agent, got)
+	}
+
+	// Deleted transcripts are reported, and stopping flushes what's pending
+	if err := os.Remove(existing); err != nil {
+		t.Fatalf("failed to delete transcript: %v", err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected a clean stop, got %v", err)
+	}
+	if got := next(); !reflect.DeepEqual(got, []string{existing}) {
+		t.Errorf("expected %s, got %v", existing, got)
+	}
+}
+
+func TestIndexer_IndexPaths(t *testing.T) {
+	mockDB := db.NewMock()
+	tmpDir := t.TempDir()
+
+	project := filepath.Join(tmpDir, "-work-app")
+	if err := os.MkdirAll(project, 0755); err != nil {
+		t.Fatalf("failed to create project directory: %v", err)
+	}
+	kept := filepath.Join(project, "kept.jsonl")
+	deleted := filepath.Join(project, "deleted.jsonl")
+	for _, path := range []string{kept, deleted} {
+		content := `{"type":"user","uuid":"u1","timestamp":"2026-01-05T10:00:00Z","message":{"content":"Hello"}}` + "\n"
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to create test file: %v", err)
+		}
+	}
+
+	indexer := NewIndexer(mockDB, tmpDir)
+	if err := indexer.IndexPaths([]string{kept, deleted, filepath.Join(tmpDir, "stray.jsonl")}); err != nil {
+		t.Fatalf("failed to index paths: %v", err)
+	}
+	for _, uuid := range []string{"kept", "deleted"} {
+		if len(mockDB.GetMessages(uuid)) != 1 {
+			t.Errorf("expected %s to be indexed", uuid)
+		}
+	}
+
+	if err := os.Remove(deleted); err != nil {
+		t.Fatalf("failed to delete transcript: %v", err)
+	}
+	if err := indexer.IndexPaths([]string{deleted}); err != nil {
+		t.Fatalf("failed to index paths: %v", err)
+	}
+	if state, _ := mockDB.GetIndexState("deleted"); state != nil {
+		t.Errorf("expected the deleted transcript to be removed, got %+v", state)
+	}
+	if len(mockDB.GetMessages("kept")) != 1 {
+		t.Error("expected the other conversation to be kept")
+	}
+}
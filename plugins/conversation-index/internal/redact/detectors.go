@@ -0,0 +1,86 @@
+package redact
+
+import (
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Patterns of the built-in detectors
+var (
+	privateKeyPattern   = regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?(?:-----END [A-Z ]*PRIVATE KEY-----|\z)`)
+	awsAccessKeyPattern = regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b`)
+	awsSecretKeyPattern = regexp.MustCompile(`(?i)aws_secret_access_key["']?\s*[=:]\s*["']?([A-Za-z0-9/+=]{40})`)
+	githubTokenPattern  = regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{36,255}|github_pat_[A-Za-z0-9_]{22,255})\b`)
+	apiKeyPattern       = regexp.MustCompile(`\bsk-(?:ant-|proj-)?[A-Za-z0-9_-]{20,}`)
+	jwtPattern          = regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{5,}\.eyJ[A-Za-z0-9_-]{5,}\.[A-Za-z0-9_-]{10,}`)
+	urlPasswordPattern  = regexp.MustCompile(`[A-Za-z][A-Za-z0-9+.-]*://[^\s:/@]+:([^\s@/]+)@`)
+
+	// Values of variables, keys and flags named like secrets:
+	// DB_PASSWORD=..., "api_key": "...", --token ...
+	assignmentPattern = regexp.MustCompile(`(?i)(?:[a-z0-9_.-]*(?:password|passwd|secret|token|api[_-]?key|access[_-]?key)[a-z0-9_.-]*["']?\s*[=:]\s*|--(?:password|passwd|token|secret)[= ])["']?([^\s"'&;,]+)`)
+
+	// Long runs of base64 and URL-safe characters
+	entropyPattern = regexp.MustCompile(`[A-Za-z0-9+=_-]{24,}`)
+)
+
+// minEntropy is the Shannon entropy in bits per character above which a
+// mixed-case alphanumeric string is taken to be random
+const minEntropy = 4.0
+
+// Builtins returns the built-in detectors, most specific first, so a GitHub
+// token assigned to GITHUB_TOKEN is reported as a github_token
+func Builtins() []Detector {
+	return []Detector{
+		{Name: "private_key", Pattern: privateKeyPattern},
+		{Name: "aws_access_key", Pattern: awsAccessKeyPattern},
+		{Name: "aws_secret_key", Pattern: awsSecretKeyPattern},
+		{Name: "github_token", Pattern: githubTokenPattern},
+		{Name: "api_key", Pattern: apiKeyPattern},
+		{Name: "jwt", Pattern: jwtPattern},
+		{Name: "url_password", Pattern: urlPasswordPattern},
+		{Name: "secret", Pattern: assignmentPattern, Check: isSecretValue},
+		{Name: "high_entropy", Pattern: entropyPattern, Check: isHighEntropy},
+	}
+}
+
+// isSecretValue rejects assigned values that are references or too short to
+// be secrets, such as $TOKEN or true
+func isSecretValue(value string) bool {
+	if strings.HasPrefix(value, "$") || strings.HasPrefix(value, "<") {
+		return false
+	}
+	switch strings.ToLower(value) {
+	case "true", "false", "null", "none", "undefined":
+		return false
+	}
+	return len(value) >= 6
+}
+
+// isHighEntropy reports whether s mixes upper case, lower case and digits
+// and is random enough to be a key rather than an identifier
+func isHighEntropy(s string) bool {
+	var upper, lower, digit bool
+	for _, r := range s {
+		upper = upper || unicode.IsUpper(r)
+		lower = lower || unicode.IsLower(r)
+		digit = digit || unicode.IsDigit(r)
+	}
+	return upper && lower && digit && entropy(s) >= minEntropy
+}
+
+// entropy returns the Shannon entropy of s in bits per character
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+	n := float64(len(s))
+	bits := 0.0
+	for _, c := range counts {
+		p := float64(c) / n
+		bits -= p * math.Log2(p)
+	}
+	return bits
+}
@@ -0,0 +1,120 @@
+// Package redact replaces secrets in transcript content with typed
+// placeholders before it is indexed
+package redact
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/doughughes/claude-marketplace/plugins/conversation-index/internal/shared"
+)
+
+// placeholderPrefix starts every placeholder, so redacted text is left alone
+// when it is redacted again
+const placeholderPrefix = "[REDACTED:"
+
+// Placeholder returns the text that replaces a secret found by a detector
+func Placeholder(name string) string {
+	return placeholderPrefix + name + "]"
+}
+
+// Detector finds one type of secret. If Pattern has a capture group only the
+// first group is replaced, so surrounding context such as a variable name
+// stays searchable.
+type Detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+	Check   func(secret string) bool // Optional; rejects matches that aren't secrets
+}
+
+// redact replaces the secrets d finds in s
+func (d Detector) redact(s string) string {
+	matches := d.Pattern.FindAllStringSubmatchIndex(s, -1)
+	if matches == nil {
+		return s
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		if len(m) >= 4 && m[2] >= 0 {
+			start, end = m[2], m[3]
+		}
+		secret := s[start:end]
+		if secret == "" || strings.HasPrefix(secret, placeholderPrefix) || (d.Check != nil && !d.Check(secret)) {
+			continue
+		}
+		b.WriteString(s[last:start])
+		b.WriteString(Placeholder(d.Name))
+		last = end
+	}
+	b.WriteString(s[last:])
+	return b.String()
+}
+
+// Redactor applies detectors in order
+type Redactor struct {
+	detectors []Detector
+}
+
+// New creates a redactor from detectors
+func New(detectors []Detector) *Redactor {
+	return &Redactor{detectors: detectors}
+}
+
+// Redact replaces secrets in s with placeholders. A nil redactor returns s
+// unchanged.
+func (r *Redactor) Redact(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, d := range r.detectors {
+		s = d.redact(s)
+	}
+	return s
+}
+
+// FromConfig creates a redactor with the built-in detectors the config
+// doesn't skip, followed by its patterns. It returns nil if redaction is
+// disabled.
+func FromConfig(cfg shared.RedactionConfig) (*Redactor, error) {
+	if cfg.Disabled {
+		return nil, nil
+	}
+
+	skip := make(map[string]bool)
+	builtins := Builtins()
+	for _, name := range cfg.SkipDetectors {
+		found := false
+		for _, d := range builtins {
+			found = found || d.Name == name
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown detector %q", name)
+		}
+		skip[name] = true
+	}
+
+	var detectors []Detector
+	for _, d := range builtins {
+		if !skip[d.Name] {
+			detectors = append(detectors, d)
+		}
+	}
+
+	for _, p := range cfg.Patterns {
+		pattern, err := regexp.Compile(p.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p.Name, err)
+		}
+		name := p.Name
+		if name == "" {
+			name = "custom"
+		}
+		detectors = append(detectors, Detector{Name: name, Pattern: pattern})
+	}
+
+	return New(detectors), nil
+}
@@ -1,6 +1,8 @@
 package shared
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
 )
@@ -10,4 +12,86 @@ var (
 	ClaudeDir   = filepath.Join(os.Getenv("HOME"), ".claude")
 	ProjectsDir = filepath.Join(ClaudeDir, "projects")
 	DBPath      = filepath.Join(ClaudeDir, "conversation-index.db")
+	ConfigPath  = filepath.Join(ClaudeDir, "conversation-index.json")
+	LockPath    = filepath.Join(ClaudeDir, "conversation-index.lock") // Taken while indexing
+
+	// Held by cidx-index --watch while it runs; hooks skip indexing when they
+	// can't take it
+	WatchLockPath = filepath.Join(ClaudeDir, "conversation-index.watch.lock")
 )
+
+// Config holds optional user settings read from ConfigPath
+type Config struct {
+	Ranking   RankingConfig   `json:"ranking"`
+	Tokenizer TokenizerConfig `json:"tokenizer"`
+	Indexing  IndexingConfig  `json:"indexing"`
+	Retention RetentionConfig `json:"retention"`
+	Redaction RedactionConfig `json:"redaction"`
+}
+
+// RankingConfig overrides the default search ranking weights. Zero values
+// keep the defaults.
+type RankingConfig struct {
+	ContentWeight   float64            `json:"content_weight"`
+	RoleWeights     map[string]float64 `json:"role_weights"`
+	RecencyHalfLife string             `json:"recency_half_life"` // e.g. "90d"
+}
+
+// TokenizerConfig selects the search index tokenizer. Changing it rebuilds
+// the index on the next indexing run.
+type TokenizerConfig struct {
+	Porter           bool   `json:"porter"`
+	RemoveDiacritics *int   `json:"remove_diacritics"` // 0, 1 or 2; unset keeps 1
+	TokenChars       string `json:"tokenchars"`        // e.g. "_."
+}
+
+// IndexingConfig enables optional content in the index. It applies to
+// lines indexed from then on; a full reindex applies it to everything.
+type IndexingConfig struct {
+	ToolResults        bool `json:"tool_results"`          // Index tool output under the tool_result role
+	MaxToolResultBytes int  `json:"max_tool_result_bytes"` // Cap per tool result, 0 for the default
+	Thinking           bool `json:"thinking"`              // Index assistant reasoning under the thinking role
+}
+
+// RetentionConfig removes conversations from the index once their
+// transcript hasn't changed for a while. Durations are like "180d"; "0"
+// keeps conversations forever.
+type RetentionConfig struct {
+	PruneOlderThan string            `json:"prune_older_than"` // Default for all projects
+	Projects       map[string]string `json:"projects"`         // Overrides by project path
+}
+
+// RedactionConfig controls how secrets are replaced before content is
+// indexed. The built-in detectors are on unless skipped.
+type RedactionConfig struct {
+	Disabled      bool               `json:"disabled"`       // Index content unredacted
+	SkipDetectors []string           `json:"skip_detectors"` // Built-in detectors to turn off, e.g. "high_entropy"
+	Patterns      []RedactionPattern `json:"patterns"`       // Extra detectors
+}
+
+// RedactionPattern is a user-defined detector. If the regex has a capture
+// group, only the first group is replaced.
+type RedactionPattern struct {
+	Name  string `json:"name"` // Placeholder type, e.g. "internal_host"
+	Regex string `json:"regex"`
+}
+
+// LoadConfig reads the config file at path. A missing file is not an error
+// and yields an empty config.
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,54 @@
+package shared
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	// Missing file yields an empty config
+	cfg, err := LoadConfig(filepath.Join(tmpDir, "missing.json"))
+	if err != nil {
+		t.Fatalf("failed to load missing config: %v", err)
+	}
+	if cfg.Ranking.ContentWeight != 0 || cfg.Ranking.RoleWeights != nil {
+		t.Errorf("expected empty config, got %+v", cfg)
+	}
+
+	path := filepath.Join(tmpDir, "config.json")
+	content := `{"ranking": {"role_weights": {"tool": 0.2}, "recency_half_life": "90d"},
+		"tokenizer": {"porter": true, "remove_diacritics": 0},
+		"retention": {"prune_older_than": "180d", "projects": {"/work/legal": "0"}}}`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	cfg, err = LoadConfig(path)
+	if err != nil {
+		t.Fatalf("failed to load config: %v", err)
+	}
+	if cfg.Ranking.RoleWeights["tool"] != 0.2 {
+		t.Errorf("expected tool weight 0.2, got %v", cfg.Ranking.RoleWeights["tool"])
+	}
+	if cfg.Ranking.RecencyHalfLife != "90d" {
+		t.Errorf("expected half life 90d, got %q", cfg.Ranking.RecencyHalfLife)
+	}
+	if !cfg.Tokenizer.Porter || cfg.Tokenizer.RemoveDiacritics == nil || *cfg.Tokenizer.RemoveDiacritics != 0 {
+		t.Errorf("expected porter tokenizer without diacritics removal, got %+v", cfg.Tokenizer)
+	}
+
+	if cfg.Retention.PruneOlderThan != "180d" || cfg.Retention.Projects["/work/legal"] != "0" {
+		t.Errorf("expected 180d retention with a legal override, got %+v", cfg.Retention)
+	}
+
+	// Invalid JSON is reported
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	if _, err := LoadConfig(path); err == nil {
+		t.Error("expected error for invalid config, got nil")
+	}
+}
@@ -0,0 +1,57 @@
+package shared
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrLocked is returned when a lock is still held by another process after
+// the timeout
+var ErrLocked = errors.New("lock is held by another process")
+
+// lockPollInterval is how often AcquireLock retries a held lock
+const lockPollInterval = 100 * time.Millisecond
+
+// Lock is an exclusive advisory lock on a file. cidx-index processes (hook,
+// watch, maintain and redact) share the one on LockPath, so only one of them
+// writes the index at a time.
+type Lock struct {
+	file *os.File
+}
+
+// AcquireLock takes the lock on path, waiting up to timeout for another
+// process to release it
+func AcquireLock(path string, timeout time.Duration) (*Lock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLock(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("failed to lock %s: %w", path, err)
+		}
+		if locked {
+			return &Lock{file: file}, nil
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, ErrLocked
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// Release releases the lock
+func (l *Lock) Release() error {
+	if err := unlock(l.file); err != nil {
+		l.file.Close()
+		return fmt.Errorf("failed to unlock: %w", err)
+	}
+	return l.file.Close()
+}
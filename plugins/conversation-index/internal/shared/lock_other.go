@@ -0,0 +1,16 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package shared
+
+import "os"
+
+// tryLock always succeeds where flock isn't available, so cidx-index
+// processes don't exclude each other there
+func tryLock(file *os.File) (bool, error) {
+	return true, nil
+}
+
+// unlock is a no-op where flock isn't available
+func unlock(file *os.File) error {
+	return nil
+}
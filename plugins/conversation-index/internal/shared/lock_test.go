@@ -0,0 +1,34 @@
+package shared
+
+import (
+	"errors"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestAcquireLock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("flock is not available")
+	}
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	lock, err := AcquireLock(path, 0)
+	if err != nil {
+		t.Fatalf("failed to acquire lock: %v", err)
+	}
+
+	if _, err := AcquireLock(path, lockPollInterval); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected ErrLocked while held, got %v", err)
+	}
+
+	if err := lock.Release(); err != nil {
+		t.Fatalf("failed to release lock: %v", err)
+	}
+
+	lock, err = AcquireLock(path, 0)
+	if err != nil {
+		t.Fatalf("failed to acquire released lock: %v", err)
+	}
+	lock.Release()
+}
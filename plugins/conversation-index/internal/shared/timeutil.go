@@ -2,12 +2,17 @@ package shared
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
 // RFC3339Millis is the timestamp format used in Claude Code JSONL files
 const RFC3339Millis = "2006-01-02T15:04:05.999Z07:00"
 
+// DateOnly is the format accepted for calendar dates in command line flags
+const DateOnly = "2006-01-02"
+
 // ParseTimestamp parses various ISO 8601 timestamp formats
 func ParseTimestamp(s string) (time.Time, error) {
 	// Try RFC3339 with milliseconds
@@ -41,3 +46,56 @@ func TruncateString(s string, maxLen int) string {
 	}
 	return string(runes[:maxLen-3]) + "..."
 }
+
+// CompactWhitespace collapses all runs of whitespace, including newlines,
+// into single spaces
+func CompactWhitespace(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// ParseDuration extends time.ParseDuration with day (d) and week (w) units,
+// so relative ages for --since and --until like "7d" or "2w" are accepted
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, fmt.Errorf("empty duration")
+	}
+
+	unit := time.Duration(0)
+	switch s[len(s)-1] {
+	case 'd':
+		unit = 24 * time.Hour
+	case 'w':
+		unit = 7 * 24 * time.Hour
+	}
+
+	if unit == 0 {
+		return time.ParseDuration(s)
+	}
+
+	n, err := strconv.ParseFloat(s[:len(s)-1], 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid duration: %s", s)
+	}
+	return time.Duration(n * float64(unit)), nil
+}
+
+// ParseDate parses a date given as YYYY-MM-DD (local time), a full timestamp,
+// or a relative age such as "7d" meaning that long before now
+func ParseDate(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+
+	if t, err := time.ParseInLocation(DateOnly, s, time.Local); err == nil {
+		return t, nil
+	}
+
+	if t, err := ParseTimestamp(s); err == nil {
+		return t, nil
+	}
+
+	if d, err := ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse date: %s", s)
+}